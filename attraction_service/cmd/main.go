@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"diplomaPorject/backend/attraction/internal/routes"
+	"diplomaPorject/backend/attraction/internal/scheduler"
 	"diplomaPorject/backend/attraction/utils/db"
 	"log"
 	"net/http"
@@ -9,6 +11,7 @@ import (
 
 func main() {
 	db.ConnectDB()
+	scheduler.Start(context.Background())
 	router := routes.SetupRoutes()
 	log.Println("Attraction service running on port 8085...")
 	log.Fatal(http.ListenAndServe(":8085", router))
@@ -1,33 +1,50 @@
 package controllers
 
 import (
-	"crypto/rand"
 	"diplomaPorject/backend/attraction/internal/models"
 	"diplomaPorject/backend/attraction/utils/db"
-	"encoding/hex"
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/audit"
+	"diplomaPorject/backend/shared/gallery"
+	"diplomaPorject/backend/shared/geo"
+	"diplomaPorject/backend/shared/media"
+	"diplomaPorject/backend/shared/update"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
-	"io"
+	"gorm.io/gorm"
+	"log"
 	"math"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"time"
 )
 
-func generateRandomFilename(originalFilename string) string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes) + filepath.Ext(originalFilename)
-}
+// attractionFromFields validates the fields of an attraction. value returns
+// the raw text of a field, as sent by the create form, an update or an
+// import row.
+func attractionFromFields(value func(string) string) (*models.Attraction, error) {
+	latitude, longitude, err := geo.ParseCoordinateFields(value)
+	if err != nil {
+		return nil, err
+	}
+
+	openingHours, err := parseOpeningHoursField(value("opening_hours"))
+	if err != nil {
+		return nil, err
+	}
 
-type AttractionRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	City        string `json:"city"`
-	Location    string `json:"location"`
-	ImageURL    string `json:"image_url"`
+	return &models.Attraction{
+		Title:        value("title"),
+		Description:  value("description"),
+		City:         value("city"),
+		Location:     value("location"),
+		ImageURL:     value("image_url"),
+		Latitude:     latitude,
+		Longitude:    longitude,
+		OpeningHours: openingHours,
+	}, nil
 }
 
 func CreateAttraction(w http.ResponseWriter, r *http.Request) {
@@ -47,29 +64,45 @@ func CreateAttraction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, header, err := r.FormFile("image")
+	attraction, err := attractionFromFields(r.FormValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("image")
 	if err != nil {
 		http.Error(w, "No image file provided", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 
-	imageURL, err := uploadImage(file, header.Filename)
+	variants, err := media.UploadImage(r, file)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to upload image: %v", err), http.StatusInternalServerError)
+		media.WriteUploadError(w, err)
 		return
 	}
-
-	attraction := models.Attraction{
-		Title:       r.FormValue("title"),
-		Description: r.FormValue("description"),
-		City:        r.FormValue("city"),
-		Location:    r.FormValue("location"),
-		AdminID:     adminID,
-		ImageURL:    imageURL,
+	// Further "images" files fill the gallery after the cover image.
+	extra, err := gallery.UploadForm(r)
+	if err != nil {
+		media.WriteUploadError(w, err)
+		return
 	}
+	uploads := append([]gallery.Upload{{Variants: variants}}, extra...)
 
-	if err := db.DB.Create(&attraction).Error; err != nil {
+	attraction.AdminID = adminID
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attraction).Error; err != nil {
+			return err
+		}
+		images, err := attractionGallery.Add(tx, attraction, uploads)
+		if err != nil {
+			return err
+		}
+		attraction.Images = images
+		return models.AttractionAudit.Record(tx, adminID, audit.ActionCreate, attraction.ID, nil, attraction)
+	})
+	if err != nil {
 		http.Error(w, "Failed to create attraction", http.StatusInternalServerError)
 		return
 	}
@@ -77,78 +110,173 @@ func CreateAttraction(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(attraction)
 }
-func uploadImage(file io.Reader, filename string) (string, error) {
-	// ✅ Use absolute path for consistency
-	uploadDir := "/app/uploads" // Matches Docker volume mount path
-
-	// Create uploads directory if it doesn't exist
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %v", err)
-	}
-
-	randomFilename := generateRandomFilename(filename)
-	filePath := filepath.Join(uploadDir, randomFilename) // Use filePath for saving
-
-	dst, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file: %v", err)
-	}
-	defer dst.Close()
-
-	// Save the uploaded file
-	if _, err := io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("failed to save file: %v", err)
-	}
-
-	// Return URL path for accessing the image
-	return fmt.Sprintf("/uploads/%s", randomFilename), nil
-}
 
 func GetAttraction(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 	var attraction models.Attraction
-	if err := db.DB.First(&attraction, id).Error; err != nil {
+	if err := db.DB.Preload("Images", gallery.Ordered).First(&attraction, id).Error; err != nil {
 		http.Error(w, "Not found event", http.StatusNotFound)
 		return
 	}
+	w.Header().Set("ETag", update.EntityTag(attraction.UpdatedAt))
 	json.NewEncoder(w).Encode(attraction)
 }
 
+// applyAttractionUpdate copies the editable fields of updated onto
+// attraction.
+func applyAttractionUpdate(attraction, updated *models.Attraction) {
+	attraction.Title = updated.Title
+	attraction.Description = updated.Description
+	attraction.City = updated.City
+	attraction.Location = updated.Location
+	if updated.ImageURL != attraction.ImageURL {
+		// The variants belong to the replaced image.
+		attraction.ImageVariants = nil
+	}
+	attraction.ImageURL = updated.ImageURL
+	attraction.Latitude = updated.Latitude
+	attraction.Longitude = updated.Longitude
+	attraction.OpeningHours = updated.OpeningHours
+}
+
+// UpdateAttraction replaces every editable field of an attraction; fields
+// left out of the request are cleared. Use PatchAttraction to change single
+// fields.
 func UpdateAttraction(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	updateAttraction(w, r, false)
+}
 
-	var req AttractionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Error with decoding", http.StatusBadRequest)
+// PatchAttraction changes only the fields that were sent. It accepts the
+// same JSON or multipart body as UpdateAttraction.
+func PatchAttraction(w http.ResponseWriter, r *http.Request) {
+	updateAttraction(w, r, true)
+}
+
+// updateAttraction applies an update request to an attraction, validating
+// the result like CreateAttraction. A multipart request may carry a
+// replacement "image", which becomes the gallery cover. If-Match is checked
+// against the attraction's ETag to avoid lost updates.
+//
+// Nothing is uploaded for a request that fails its checks, and the upload
+// runs before the attraction is locked. The checks are repeated under the
+// lock, since the attraction may have changed meanwhile. An upload whose
+// save fails is left unreferenced, and the media service collects it.
+func updateAttraction(w http.ResponseWriter, r *http.Request, partial bool) {
+	fields, image, err := update.ReadRequest(r, attractionColumns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var attraction models.Attraction
-	if err := db.DB.First(&attraction, id).Error; err != nil {
-		http.Error(w, "Error with searching attraction", http.StatusBadRequest)
+	if image != nil {
+		defer image.Close()
+	}
+	id := mux.Vars(r)["id"]
+
+	attraction, err := findAttraction(db.DB, id)
+	if err == nil {
+		_, err = checkAttractionUpdate(r, attraction, fields, partial, image != nil)
+	}
+	if err != nil {
+		writeUpdateError(w, err)
 		return
 	}
 
-	attraction.Title = req.Title
-	attraction.Description = req.Description
-	attraction.City = req.City
-	attraction.Location = req.Location
-	attraction.ImageURL = req.ImageURL
+	var variants models.ImageVariants
+	if image != nil {
+		if variants, err = media.UploadImage(r, image); err != nil {
+			media.WriteUploadError(w, err)
+			return
+		}
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		attraction, err = lockAttraction(tx, id)
+		if err != nil {
+			return err
+		}
+		updated, err := checkAttractionUpdate(r, attraction, fields, partial, image != nil)
+		if err != nil {
+			return err
+		}
+		before, err := audit.Snapshot(attraction)
+		if err != nil {
+			return err
+		}
 
-	if err := db.DB.Save(&attraction).Error; err != nil {
-		http.Error(w, "Can't update attraction", http.StatusBadRequest)
+		applyAttractionUpdate(attraction, updated)
+		if err := tx.Save(attraction).Error; err != nil {
+			return err
+		}
+		if variants != nil {
+			if err := attractionGallery.ReplaceCover(tx, attraction, variants); err != nil {
+				return err
+			}
+		}
+		if err := tx.Preload("Images", gallery.Ordered).First(attraction, attraction.ID).Error; err != nil {
+			return err
+		}
+		return models.AttractionAudit.Record(tx, access.AdminID(r), audit.ActionUpdate, attraction.ID, before, attraction)
+	})
+	if err != nil {
+		writeUpdateError(w, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(&attraction)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", update.EntityTag(attraction.UpdatedAt))
+	json.NewEncoder(w).Encode(attraction)
+}
+
+// checkAttractionUpdate checks that the admin may apply the update to the
+// attraction as it is now and returns the attraction the update describes.
+func checkAttractionUpdate(r *http.Request, attraction *models.Attraction, fields map[string]string, partial, hasImage bool) (*models.Attraction, error) {
+	if !access.MayEdit(r, attraction.AdminID) {
+		return nil, access.ErrNotOwner
+	}
+	if !update.IfMatch(r, attraction.UpdatedAt) {
+		return nil, update.ErrPreconditionFailed
+	}
+
+	merged := fields
+	if partial {
+		var err error
+		if merged, err = update.MergeFields(attractionColumns, attractionRecord(attraction), fields); err != nil {
+			return nil, err
+		}
+	}
+	if hasImage {
+		// The uploaded file takes the place of any image_url sent.
+		merged["image_url"] = attraction.ImageURL
+	}
+	updated, err := attractionFromFields(func(name string) string { return merged[name] })
+	if err != nil {
+		return nil, &update.InvalidError{Err: err}
+	}
+	return updated, nil
 }
+
 func DeleteAttraction(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-	if err := db.DB.Delete(&models.Attraction{}, id).Error; err != nil {
-		http.Error(w, "Cant delete attraction", http.StatusBadRequest)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		attraction, err := lockAttraction(tx, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(attraction).Error; err != nil {
+			return err
+		}
+		return models.AttractionAudit.Record(tx, access.AdminID(r), audit.ActionDelete, attraction.ID, attraction, nil)
+	})
+	if errors.Is(err, ErrAttractionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete attraction: %v", err)
+		http.Error(w, "Cant delete attraction", http.StatusInternalServerError)
+		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -164,32 +292,108 @@ func ListAttractions(w http.ResponseWriter, r *http.Request) {
 }
 
 func PublishAttraction(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	setAttractionPublished(w, r, true)
+}
+
+func UnpublishAttraction(w http.ResponseWriter, r *http.Request) {
+	setAttractionPublished(w, r, false)
+}
+
+// setAttractionPublished publishes or unpublishes an attraction by hand,
+// which also clears the matching scheduled time.
+func setAttractionPublished(w http.ResponseWriter, r *http.Request, published bool) {
+	action, scheduleColumn := audit.ActionPublish, "publish_at"
+	if !published {
+		action, scheduleColumn = audit.ActionUnpublish, "unpublish_at"
+	}
 
-	if err := db.DB.Model(&models.Attraction{}).Where("id = ?", id).Update("is_published", true).Error; err != nil {
-		http.Error(w, "Failed to publish attractions", http.StatusInternalServerError)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		attraction, err := lockAttraction(tx, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+		before, err := audit.Snapshot(attraction)
+		if err != nil {
+			return err
+		}
+
+		attraction.IsPublished = published
+		if published {
+			attraction.PublishAt = nil
+		} else {
+			attraction.UnpublishAt = nil
+		}
+		if err := tx.Model(attraction).Select("is_published", scheduleColumn).Updates(attraction).Error; err != nil {
+			return err
+		}
+		return models.AttractionAudit.Record(tx, access.AdminID(r), action, attraction.ID, before, attraction)
+	})
+	if errors.Is(err, ErrAttractionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to %s attraction: %v", action, err)
+		http.Error(w, fmt.Sprintf("Failed to %s attraction", action), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func UnpublishAttraction(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+type attractionPage struct {
+	Attractions []models.Attraction
+	Total       int64
+	Page        int
+	PageSize    int
+	TotalPages  int
+}
 
-	if err := db.DB.Model(&models.Attraction{}).Where("id = ?", id).Update("is_published", false).Error; err != nil {
-		http.Error(w, "Failed to unpublish event", http.StatusInternalServerError)
-		return
-	}
+// errBadFilter marks listing errors caused by invalid query parameters.
+type errBadFilter struct{ message string }
 
-	w.WriteHeader(http.StatusOK)
+func (e *errBadFilter) Error() string { return e.message }
+
+// parseOpenFilter reads open_now=true or open_at=<RFC3339>. It returns nil
+// when neither is set.
+func parseOpenFilter(r *http.Request) (*time.Time, error) {
+	if value := r.URL.Query().Get("open_at"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, &errBadFilter{"open_at must be an RFC3339 timestamp"}
+		}
+		return &t, nil
+	}
+	if value := r.URL.Query().Get("open_now"); value != "" {
+		openNow, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, &errBadFilter{"open_now must be true or false"}
+		}
+		if openNow {
+			now := time.Now()
+			return &now, nil
+		}
+	}
+	return nil, nil
 }
 
-func ListPublishedAttractions(w http.ResponseWriter, r *http.Request) {
-	var attractions []models.Attraction
-	query := db.DB.Where("is_published = ?", true)
+// loadPublishedAttractions applies the public city and opening-hours filters
+// and pagination. The scope narrows the query further, e.g. to attractions
+// with coordinates.
+func loadPublishedAttractions(r *http.Request, scope func(*gorm.DB) *gorm.DB) (*attractionPage, error) {
+	query := db.DB.Model(&models.Attraction{}).Where("is_published = ?", true)
+	if city := r.URL.Query().Get("city"); city != "" {
+		query = query.Where("LOWER(city) = LOWER(?)", city)
+	}
+	if scope != nil {
+		query = query.Scopes(scope)
+	}
+
+	openAt, err := parseOpenFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
 	page := 1
 	pageSize := 10
 
@@ -198,17 +402,74 @@ func ListPublishedAttractions(w http.ResponseWriter, r *http.Request) {
 			page = p
 		}
 	}
+	if sizeParam := r.URL.Query().Get("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 {
+			pageSize = s
+		}
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
 
 	offset := (page - 1) * pageSize
 
 	var totalCount int64
-	query.Model(&models.Attraction{}).Count(&totalCount)
-	if err := query.
-		Order("title ASC").
-		Offset(offset).
-		Limit(pageSize).
-		Find(&attractions).Error; err != nil {
-		http.Error(w, "Failed to fetch attractions", http.StatusInternalServerError)
+	var attractions []models.Attraction
+	if openAt != nil {
+		// Schedules are evaluated in Go, so the open filter pages in memory.
+		var candidates []models.Attraction
+		if err := query.Where("opening_hours IS NOT NULL").Order("title ASC").Find(&candidates).Error; err != nil {
+			return nil, err
+		}
+		for _, attraction := range candidates {
+			if attraction.OpeningHours.IsOpenAt(*openAt) {
+				attractions = append(attractions, attraction)
+			}
+		}
+
+		totalCount = int64(len(attractions))
+		if offset >= len(attractions) {
+			attractions = []models.Attraction{}
+		} else {
+			attractions = attractions[offset:min(offset+pageSize, len(attractions))]
+		}
+	} else {
+		if err := query.Session(&gorm.Session{}).Count(&totalCount).Error; err != nil {
+			return nil, err
+		}
+
+		if err := query.
+			Order("title ASC").
+			Offset(offset).
+			Limit(pageSize).
+			Find(&attractions).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &attractionPage{
+		Attractions: attractions,
+		Total:       totalCount,
+		Page:        page,
+		PageSize:    pageSize,
+		TotalPages:  int(math.Ceil(float64(totalCount) / float64(pageSize))),
+	}, nil
+}
+
+// writeListError reports invalid filters as 400 and anything else as 500.
+func writeListError(w http.ResponseWriter, err error) {
+	var badFilter *errBadFilter
+	if errors.As(err, &badFilter) {
+		http.Error(w, badFilter.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Failed to fetch attractions", http.StatusInternalServerError)
+}
+
+func ListPublishedAttractions(w http.ResponseWriter, r *http.Request) {
+	page, err := loadPublishedAttractions(r, nil)
+	if err != nil {
+		writeListError(w, err)
 		return
 	}
 
@@ -219,13 +480,29 @@ func ListPublishedAttractions(w http.ResponseWriter, r *http.Request) {
 		PageSize    int                 `json:"page_size"`
 		TotalPages  int                 `json:"total_pages"`
 	}{
-		Attractions: attractions,
-		Total:       totalCount,
-		Page:        page,
-		PageSize:    pageSize,
-		TotalPages:  int(math.Ceil(float64(totalCount) / float64(pageSize))),
+		Attractions: page.Attractions,
+		Total:       page.Total,
+		Page:        page.Page,
+		PageSize:    page.PageSize,
+		TotalPages:  page.TotalPages,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// parseOpeningHoursField reads the optional JSON "opening_hours" field.
+func parseOpeningHoursField(value string) (*models.OpeningHours, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var hours models.OpeningHours
+	if err := json.Unmarshal([]byte(value), &hours); err != nil {
+		return nil, errors.New("Invalid opening hours: malformed JSON")
+	}
+	if err := hours.Validate(); err != nil {
+		return nil, errors.New("Invalid opening hours: " + err.Error())
+	}
+	return &hours, nil
+}
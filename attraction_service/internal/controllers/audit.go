@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/attraction/internal/models"
+	"diplomaPorject/backend/attraction/utils/db"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// parseAuditTime reads a from/to bound, either an RFC3339 timestamp or a
+// YYYY-MM-DD date meaning the start of that day in UTC.
+func parseAuditTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, &errBadFilter{name + " must be an RFC3339 timestamp or a YYYY-MM-DD date"}
+	}
+	return &t, nil
+}
+
+func parseAuditInt(name, value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, &errBadFilter{name + " must be a positive integer"}
+	}
+	return n, nil
+}
+
+// ListAuditEntries returns the audit log, newest first. It can be filtered
+// by admin_id, entity_id, action and a from/to date range.
+func ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := db.DB.Model(&models.AuditEntry{})
+
+	for _, name := range []string{"admin_id", "entity_id"} {
+		value := q.Get(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			http.Error(w, name+" must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		query = query.Where(name+" = ?", id)
+	}
+	if action := q.Get("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	from, err := parseAuditTime("from", q.Get("from"))
+	if err != nil {
+		writeAuditError(w, err)
+		return
+	}
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	to, err := parseAuditTime("to", q.Get("to"))
+	if err != nil {
+		writeAuditError(w, err)
+		return
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+
+	page, err := parseAuditInt("page", q.Get("page"), 1)
+	if err != nil {
+		writeAuditError(w, err)
+		return
+	}
+	pageSize, err := parseAuditInt("page_size", q.Get("page_size"), defaultAuditPageSize)
+	if err != nil {
+		writeAuditError(w, err)
+		return
+	}
+	if pageSize > maxAuditPageSize {
+		pageSize = maxAuditPageSize
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		writeAuditError(w, err)
+		return
+	}
+	entries := []models.AuditEntry{}
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&entries).Error; err != nil {
+		writeAuditError(w, err)
+		return
+	}
+
+	response := struct {
+		Entries    []models.AuditEntry `json:"entries"`
+		Total      int64               `json:"total"`
+		Page       int                 `json:"page"`
+		PageSize   int                 `json:"page_size"`
+		TotalPages int                 `json:"total_pages"`
+	}{
+		Entries:    entries,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(pageSize))),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeAuditError(w http.ResponseWriter, err error) {
+	var badFilter *errBadFilter
+	if errors.As(err, &badFilter) {
+		http.Error(w, badFilter.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+}
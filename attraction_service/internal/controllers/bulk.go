@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/attraction/internal/models"
+	"diplomaPorject/backend/attraction/utils/db"
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/audit"
+	"diplomaPorject/backend/shared/bulk"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	maxImportRows  = 5000
+	maxImportBytes = 10 << 20
+)
+
+// attractionColumns are the columns of attraction imports and exports. Rows
+// with an id update that attraction; rows without one create a new one.
+// opening_hours holds the same JSON the create form accepts.
+var attractionColumns = []string{
+	"id", "title", "description", "city", "location", "image_url",
+	"latitude", "longitude", "opening_hours",
+}
+
+// ImportAttractions creates and updates attractions from a CSV or JSON
+// document. Every row is validated like CreateAttraction first, with
+// image_url standing in for the uploaded image; if any row fails, nothing is
+// written and the report lists the failures. With dry_run=true the report is
+// returned without writing even when all rows are valid.
+func ImportAttractions(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - admin ID missing", http.StatusUnauthorized)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	body, format, err := bulk.ReadUpload(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := bulk.ReadRows(body, format, attractionColumns, maxImportRows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := bulk.NewReport(dryRun, len(rows))
+	attractions := make([]*models.Attraction, len(rows))
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		attraction, err := attractionFromFields(row.Get)
+		if err == nil && attraction.ImageURL == "" {
+			err = errors.New("image_url is required")
+		}
+		if err == nil {
+			ids[i], err = row.ID()
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, bulk.RowError{Row: row.Number, Message: err.Error()})
+			continue
+		}
+		attractions[i] = attraction
+	}
+
+	owners, err := existingAttractionOwners(ids)
+	if err != nil {
+		http.Error(w, "Failed to check existing attractions", http.StatusInternalServerError)
+		return
+	}
+	for i, id := range ids {
+		if id == 0 || attractions[i] == nil {
+			continue
+		}
+		ownerID, ok := owners[id]
+		if !ok {
+			report.Errors = append(report.Errors, bulk.RowError{Row: rows[i].Number, Message: fmt.Sprintf("attraction %d not found", id)})
+		} else if !access.MayEdit(r, ownerID) {
+			report.Errors = append(report.Errors, bulk.RowError{Row: rows[i].Number, Message: fmt.Sprintf("attraction %d: %s", id, access.ErrNotOwner)})
+		}
+	}
+
+	for i, id := range ids {
+		if attractions[i] == nil {
+			continue
+		}
+		if id == 0 {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+	}
+	if len(report.Errors) > 0 {
+		bulk.WriteReport(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if dryRun {
+		bulk.WriteReport(w, http.StatusOK, report)
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		for i, attraction := range attractions {
+			if ids[i] == 0 {
+				attraction.AdminID = adminID
+				if err := tx.Create(attraction).Error; err != nil {
+					return fmt.Errorf("row %d: %w", rows[i].Number, err)
+				}
+				if err := models.AttractionAudit.Record(tx, adminID, audit.ActionCreate, attraction.ID, nil, attraction); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var current models.Attraction
+			if err := tx.First(&current, ids[i]).Error; err != nil {
+				return fmt.Errorf("row %d: %w", rows[i].Number, err)
+			}
+			before, err := audit.Snapshot(&current)
+			if err != nil {
+				return err
+			}
+			applyAttractionUpdate(&current, attraction)
+			if err := tx.Save(&current).Error; err != nil {
+				return fmt.Errorf("row %d: %w", rows[i].Number, err)
+			}
+			if err := models.AttractionAudit.Record(tx, adminID, audit.ActionUpdate, current.ID, before, &current); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Attraction import failed: %v", err)
+		http.Error(w, "Failed to import attractions", http.StatusInternalServerError)
+		return
+	}
+
+	bulk.WriteReport(w, http.StatusOK, report)
+}
+
+// attractionRecord returns the export record of an attraction, in
+// attractionColumns order.
+func attractionRecord(attraction *models.Attraction) []interface{} {
+	return []interface{}{
+		attraction.ID, attraction.Title, attraction.Description, attraction.City,
+		attraction.Location, attraction.ImageURL, attraction.Latitude, attraction.Longitude,
+		attraction.OpeningHours,
+	}
+}
+
+// existingAttractionOwners maps the IDs of existing attractions to the
+// admins who created them.
+func existingAttractionOwners(ids []uint) (map[uint]uint, error) {
+	var wanted []uint
+	for _, id := range ids {
+		if id != 0 {
+			wanted = append(wanted, id)
+		}
+	}
+	owners := make(map[uint]uint, len(wanted))
+	if len(wanted) == 0 {
+		return owners, nil
+	}
+
+	var found []models.Attraction
+	if err := db.DB.Select("id", "admin_id").Where("id IN ?", wanted).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for _, attraction := range found {
+		owners[attraction.ID] = attraction.AdminID
+	}
+	return owners, nil
+}
+
+// ExportAttractionsForImport writes every attraction in the format ImportAttractions
+// reads. It is the admin counterpart of the public GeoJSON/KML/GPX export.
+func ExportAttractionsForImport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = bulk.FormatCSV
+	}
+	if format != bulk.FormatCSV && format != bulk.FormatJSON {
+		http.Error(w, bulk.ErrUnknownFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var attractions []models.Attraction
+	query := db.DB.Order("id ASC")
+	if city := r.URL.Query().Get("city"); city != "" {
+		query = query.Where("LOWER(city) = LOWER(?)", city)
+	}
+	if err := query.Find(&attractions).Error; err != nil {
+		http.Error(w, "Failed to fetch attractions", http.StatusInternalServerError)
+		return
+	}
+
+	records := make([][]interface{}, 0, len(attractions))
+	for _, attraction := range attractions {
+		records = append(records, attractionRecord(&attraction))
+	}
+
+	if err := bulk.Write(w, format, "attractions", attractionColumns, records); err != nil {
+		log.Printf("Attraction export failed: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/attraction/internal/models"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// publicBaseURL is where clients reach the gateway; exported files are
+// opened outside the app, so links in them must be absolute.
+func publicBaseURL() string {
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	return "http://localhost:8080"
+}
+
+func absoluteURL(path string) string {
+	if path == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return publicBaseURL() + path
+}
+
+func attractionURL(attraction *models.Attraction) string {
+	return fmt.Sprintf("%s/attractions/%d", publicBaseURL(), attraction.ID)
+}
+
+func withCoordinates(tx *gorm.DB) *gorm.DB {
+	return tx.Where("latitude IS NOT NULL AND longitude IS NOT NULL")
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         uint                   `json:"id"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Xmlns      string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	ID           string        `xml:"id,attr"`
+	Name         string        `xml:"name"`
+	Description  string        `xml:"description,omitempty"`
+	ExtendedData []kmlData     `xml:"ExtendedData>Data"`
+	Point        kmlPointCoord `xml:"Point"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPointCoord struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Xmlns     string        `xml:"xmlns,attr"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+}
+
+type gpxWaypoint struct {
+	Lat         float64   `xml:"lat,attr"`
+	Lon         float64   `xml:"lon,attr"`
+	Name        string    `xml:"name"`
+	Description string    `xml:"desc,omitempty"`
+	Links       []gpxLink `xml:"link"`
+	Type        string    `xml:"type,omitempty"`
+}
+
+type gpxLink struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:"text,omitempty"`
+	Type string `xml:"type,omitempty"`
+}
+
+// ExportAttractions returns published attractions with coordinates as
+// GeoJSON, KML or GPX. It accepts the same city and pagination parameters as
+// ListPublishedAttractions; paging details are returned in headers.
+func ExportAttractions(w http.ResponseWriter, r *http.Request) {
+	format := mux.Vars(r)["format"]
+
+	page, err := loadPublishedAttractions(r, withCoordinates)
+	if err != nil {
+		writeListError(w, err)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	w.Header().Set("X-Page", strconv.Itoa(page.Page))
+	w.Header().Set("X-Page-Size", strconv.Itoa(page.PageSize))
+	w.Header().Set("X-Total-Pages", strconv.Itoa(page.TotalPages))
+
+	switch format {
+	case "geojson":
+		writeGeoJSON(w, page.Attractions)
+	case "kml":
+		writeKML(w, page.Attractions)
+	case "gpx":
+		writeGPX(w, page.Attractions)
+	default:
+		http.Error(w, "Unsupported export format", http.StatusBadRequest)
+	}
+}
+
+func writeGeoJSON(w http.ResponseWriter, attractions []models.Attraction) {
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for i := range attractions {
+		attraction := &attractions[i]
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type: "Feature",
+			ID:   attraction.ID,
+			Geometry: geoJSONPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{*attraction.Longitude, *attraction.Latitude},
+			},
+			Properties: map[string]interface{}{
+				"title":     attraction.Title,
+				"city":      attraction.City,
+				"location":  attraction.Location,
+				"image_url": absoluteURL(attraction.ImageURL),
+				"url":       attractionURL(attraction),
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	json.NewEncoder(w).Encode(collection)
+}
+
+func writeKML(w http.ResponseWriter, attractions []models.Attraction) {
+	doc := kmlDocument{Xmlns: "http://www.opengis.net/kml/2.2", Name: "TravelKZ attractions"}
+	for i := range attractions {
+		attraction := &attractions[i]
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			ID:          fmt.Sprintf("attraction-%d", attraction.ID),
+			Name:        attraction.Title,
+			Description: attraction.Description,
+			ExtendedData: []kmlData{
+				{Name: "city", Value: attraction.City},
+				{Name: "image_url", Value: absoluteURL(attraction.ImageURL)},
+				{Name: "url", Value: attractionURL(attraction)},
+			},
+			// KML coordinates are longitude,latitude.
+			Point: kmlPointCoord{Coordinates: fmt.Sprintf("%g,%g", *attraction.Longitude, *attraction.Latitude)},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+	w.Header().Set("Content-Disposition", `attachment; filename="attractions.kml"`)
+	writeXML(w, doc)
+}
+
+func writeGPX(w http.ResponseWriter, attractions []models.Attraction) {
+	doc := gpxDocument{Xmlns: "http://www.topografix.com/GPX/1/1", Version: "1.1", Creator: "TravelKZ"}
+	for i := range attractions {
+		attraction := &attractions[i]
+		waypoint := gpxWaypoint{
+			Lat:         *attraction.Latitude,
+			Lon:         *attraction.Longitude,
+			Name:        attraction.Title,
+			Description: attraction.Description,
+			Links:       []gpxLink{{Href: attractionURL(attraction), Text: attraction.Title}},
+			Type:        attraction.City,
+		}
+		if attraction.ImageURL != "" {
+			waypoint.Links = append(waypoint.Links, gpxLink{Href: absoluteURL(attraction.ImageURL), Text: "Image", Type: "image/jpeg"})
+		}
+		doc.Waypoints = append(doc.Waypoints, waypoint)
+	}
+
+	w.Header().Set("Content-Type", "application/gpx+xml")
+	w.Header().Set("Content-Disposition", `attachment; filename="attractions.gpx"`)
+	writeXML(w, doc)
+}
+
+func writeXML(w http.ResponseWriter, doc interface{}) {
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	encoder.Encode(doc)
+}
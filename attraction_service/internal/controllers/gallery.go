@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/attraction/internal/models"
+	"diplomaPorject/backend/attraction/utils/db"
+	"diplomaPorject/backend/shared/gallery"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrAttractionNotFound = errors.New("attraction not found")
+
+func findAttraction(tx *gorm.DB, id interface{}) (*models.Attraction, error) {
+	var attraction models.Attraction
+	err := tx.First(&attraction, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAttractionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attraction, nil
+}
+
+func lockAttraction(tx *gorm.DB, id interface{}) (*models.Attraction, error) {
+	return findAttraction(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+// attractionGallery stores attraction photos in attraction_images; the
+// cover photo is the one the catalogue and map pins show.
+var attractionGallery = gallery.Gallery[models.AttractionImage, *models.AttractionImage]{
+	OwnerColumn: "attraction_id",
+	New: func(attractionID uint, image gallery.Image) models.AttractionImage {
+		return models.AttractionImage{Image: image, AttractionID: attractionID}
+	},
+}
+
+// AttractionImages serves the photo routes under /admin/attractions/{id}.
+var AttractionImages = gallery.Handlers[models.AttractionImage, *models.AttractionImage]{
+	Gallery: attractionGallery,
+	DB:      func() *gorm.DB { return db.DB },
+	Load: func(tx *gorm.DB, id string) (gallery.Owner, error) {
+		attraction, err := findAttraction(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		return attraction, nil
+	},
+	NotFound: ErrAttractionNotFound,
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/attraction/internal/models"
+	"diplomaPorject/backend/attraction/utils/db"
+	"diplomaPorject/backend/shared/geo"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+type NearbyAttraction struct {
+	models.Attraction
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// findNearbyAttractions narrows candidates with an indexed bounding-box query
+// and then ranks them by exact distance.
+func findNearbyAttractions(query *geo.NearbyQuery, excludeID uint) ([]NearbyAttraction, error) {
+	box := geo.BoundingBoxAround(query.Lat, query.Lng, query.RadiusKm)
+
+	var candidates []models.Attraction
+	if err := db.DB.Where("is_published = ?", true).
+		Where("latitude BETWEEN ? AND ?", box.MinLat, box.MaxLat).
+		Where("longitude BETWEEN ? AND ?", box.MinLng, box.MaxLng).
+		Where("id <> ?", excludeID).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]NearbyAttraction, 0, len(candidates))
+	for _, attraction := range candidates {
+		distance := geo.DistanceKm(query.Lat, query.Lng, *attraction.Latitude, *attraction.Longitude)
+		if distance <= query.RadiusKm {
+			results = append(results, NearbyAttraction{Attraction: attraction, DistanceKm: distance})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].DistanceKm < results[j].DistanceKm
+	})
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+func ListNearbyAttractions(w http.ResponseWriter, r *http.Request) {
+	query, err := geo.ParseNearbyQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := findNearbyAttractions(query, 0)
+	if err != nil {
+		http.Error(w, "Failed to fetch attractions", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Attractions []NearbyAttraction `json:"attractions"`
+		Count       int                `json:"count"`
+		RadiusKm    float64            `json:"radius_km"`
+	}{
+		Attractions: results,
+		Count:       len(results),
+		RadiusKm:    query.RadiusKm,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/attraction/internal/models"
+	"diplomaPorject/backend/attraction/utils/db"
+	"diplomaPorject/backend/shared/access"
+	"net/http"
+)
+
+// attractionOwner returns the ID of the admin who created the attraction.
+func attractionOwner(id string) (uint, error) {
+	var attraction models.Attraction
+	if err := db.DB.Select("id", "admin_id").First(&attraction, id).Error; err != nil {
+		return 0, ErrAttractionNotFound
+	}
+	return attraction.AdminID, nil
+}
+
+// OwnerOnly guards a handler of an "/{id}" route that changes the attraction,
+// enforcing the edit policy before the handler runs.
+func OwnerOnly(next http.HandlerFunc) http.HandlerFunc {
+	return access.OwnerOnly(attractionOwner, next)
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/attraction/internal/models"
+	"diplomaPorject/backend/attraction/utils/db"
+	"diplomaPorject/backend/shared/gallery"
+	"diplomaPorject/backend/shared/geo"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+const (
+	relatedRadiusKm = 10.0
+	relatedLimit    = 5
+)
+
+// GetPublishedAttraction is the public detail view. It returns 404 for
+// unpublished attractions and includes attractions nearby, or in the same
+// city when the attraction has no coordinates.
+func GetPublishedAttraction(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var attraction models.Attraction
+	if err := db.DB.Preload("Images", gallery.Ordered).Where("is_published = ?", true).First(&attraction, id).Error; err != nil {
+		http.Error(w, "Attraction not found", http.StatusNotFound)
+		return
+	}
+
+	response := struct {
+		models.Attraction
+		Nearby   []NearbyAttraction  `json:"nearby"`
+		SameCity []models.Attraction `json:"same_city,omitempty"`
+	}{
+		Attraction: attraction,
+		Nearby:     []NearbyAttraction{},
+	}
+
+	if attraction.Latitude != nil && attraction.Longitude != nil {
+		nearby, err := findNearbyAttractions(&geo.NearbyQuery{
+			Lat:      *attraction.Latitude,
+			Lng:      *attraction.Longitude,
+			RadiusKm: relatedRadiusKm,
+			Limit:    relatedLimit,
+		}, attraction.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch nearby attractions", http.StatusInternalServerError)
+			return
+		}
+		response.Nearby = nearby
+	} else if attraction.City != "" {
+		if err := db.DB.Where("is_published = ? AND city = ? AND id <> ?", true, attraction.City, attraction.ID).
+			Order("title ASC").
+			Limit(relatedLimit).
+			Find(&response.SameCity).Error; err != nil {
+			http.Error(w, "Failed to fetch related attractions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/attraction/internal/models"
+	"diplomaPorject/backend/attraction/utils/db"
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/audit"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// ScheduleRequest sets both scheduled times at once; null or a missing field
+// clears that schedule.
+type ScheduleRequest struct {
+	PublishAt   *string `json:"publish_at"`
+	UnpublishAt *string `json:"unpublish_at"`
+}
+
+func parseOptionalTime(value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// ScheduleAttraction sets when the scheduler publishes and unpublishes the
+// attraction.
+func ScheduleAttraction(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	publishAt, err := parseOptionalTime(req.PublishAt)
+	if err != nil {
+		http.Error(w, "Invalid publish_at (expected RFC3339)", http.StatusBadRequest)
+		return
+	}
+	unpublishAt, err := parseOptionalTime(req.UnpublishAt)
+	if err != nil {
+		http.Error(w, "Invalid unpublish_at (expected RFC3339)", http.StatusBadRequest)
+		return
+	}
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		http.Error(w, "unpublish_at must be after publish_at", http.StatusBadRequest)
+		return
+	}
+
+	var attraction *models.Attraction
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		attraction, err = lockAttraction(tx, id)
+		if err != nil {
+			return err
+		}
+		before, err := audit.Snapshot(attraction)
+		if err != nil {
+			return err
+		}
+
+		attraction.PublishAt = publishAt
+		attraction.UnpublishAt = unpublishAt
+		if err := tx.Model(attraction).Updates(map[string]interface{}{
+			"publish_at":   publishAt,
+			"unpublish_at": unpublishAt,
+		}).Error; err != nil {
+			return err
+		}
+		return models.AttractionAudit.Record(tx, access.AdminID(r), audit.ActionSchedule, attraction.ID, before, attraction)
+	})
+	if errors.Is(err, ErrAttractionNotFound) {
+		http.Error(w, "Attraction not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to schedule attraction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attraction)
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/update"
+	"errors"
+	"log"
+	"net/http"
+)
+
+func writeUpdateError(w http.ResponseWriter, err error) {
+	var invalid *update.InvalidError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrAttractionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, access.ErrNotOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, update.ErrPreconditionFailed):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	default:
+		log.Printf("Failed to update attraction: %v", err)
+		http.Error(w, "Failed to update attraction", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,10 @@
+package middleware
+
+// Attraction permissions granted by the auth service's roles. The audit
+// and edit-any permissions are shared with the events service and live in
+// the access package.
+const (
+	PermAttractionsView    = "attractions.view"
+	PermAttractionsEdit    = "attractions.edit"
+	PermAttractionsPublish = "attractions.publish"
+)
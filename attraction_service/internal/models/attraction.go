@@ -2,15 +2,23 @@ package models
 
 import (
 	"gorm.io/gorm"
+	"time"
 )
 
 type Attraction struct {
 	gorm.Model
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	City        string `json:"city"`
-	Location    string `json:"location"`
-	IsPublished bool   `json:"is_published" gorm:"default:false"`
-	AdminID     uint   `json:"admin_id"`
-	ImageURL    string `json:"image_url"`
+	Title         string            `json:"title"`
+	Description   string            `json:"description"`
+	City          string            `json:"city"`
+	Location      string            `json:"location"`
+	IsPublished   bool              `json:"is_published" gorm:"default:false"`
+	AdminID       uint              `json:"admin_id"`
+	ImageURL      string            `json:"image_url"`
+	ImageVariants ImageVariants     `json:"image_variants,omitempty" gorm:"type:jsonb"`
+	Latitude      *float64          `json:"latitude" gorm:"index:idx_attraction_coordinates"`
+	Longitude     *float64          `json:"longitude" gorm:"index:idx_attraction_coordinates"`
+	OpeningHours  *OpeningHours     `json:"opening_hours,omitempty" gorm:"type:jsonb"`
+	PublishAt     *time.Time        `json:"publish_at" gorm:"index"`
+	UnpublishAt   *time.Time        `json:"unpublish_at" gorm:"index"`
+	Images        []AttractionImage `json:"images,omitempty"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "diplomaPorject/backend/shared/audit"
+
+// AuditEntry keeps the audit log of attractions in its own table.
+type AuditEntry struct {
+	audit.Entry
+}
+
+func (AuditEntry) TableName() string {
+	return "attraction_audit_entries"
+}
+
+// AttractionAudit records admin changes to attractions.
+var AttractionAudit = audit.Log{
+	Table:      AuditEntry{}.TableName(),
+	EntityType: "attraction",
+}
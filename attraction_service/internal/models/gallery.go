@@ -0,0 +1,21 @@
+package models
+
+import "diplomaPorject/backend/shared/gallery"
+
+// AttractionImage is one photo in an attraction's gallery.
+type AttractionImage struct {
+	gallery.Image
+	AttractionID uint `json:"attraction_id" gorm:"not null;index"`
+}
+
+// GalleryID and SetCoverImage let the gallery keep Attraction.ImageURL and
+// ImageVariants, which the catalogue and map pins show, in step with the
+// cover photo.
+func (a *Attraction) GalleryID() uint {
+	return a.ID
+}
+
+func (a *Attraction) SetCoverImage(url string, variants ImageVariants) {
+	a.ImageURL = url
+	a.ImageVariants = variants
+}
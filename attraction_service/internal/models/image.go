@@ -0,0 +1,9 @@
+package models
+
+import "diplomaPorject/backend/shared/media"
+
+// Attraction photos keep their rendered sizes in the shared media types.
+type (
+	ImageVariant  = media.ImageVariant
+	ImageVariants = media.ImageVariants
+)
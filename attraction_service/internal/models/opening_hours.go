@@ -0,0 +1,249 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	_ "time/tzdata"
+)
+
+// Almaty is the timezone all opening hours are expressed in.
+var Almaty = mustLoadLocation("Asia/Almaty")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+var weekdayKeys = map[string]time.Weekday{
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+	"sun": time.Sunday,
+}
+
+// TimeInterval is an "HH:MM" range within a single day. Closes may be
+// "24:00" to mean midnight; intervals crossing midnight are not supported.
+type TimeInterval struct {
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+}
+
+// WeeklyHours maps weekday keys ("mon" ... "sun") to the intervals the
+// attraction is open. A missing day means closed all day.
+type WeeklyHours map[string][]TimeInterval
+
+// SeasonalHours replaces the regular weekly schedule between two "MM-DD"
+// dates, inclusive. The range may wrap around the new year, e.g. a winter
+// season from "11-01" to "03-31".
+type SeasonalHours struct {
+	Name   string      `json:"name"`
+	From   string      `json:"from"`
+	To     string      `json:"to"`
+	Closed bool        `json:"closed"`
+	Weekly WeeklyHours `json:"weekly,omitempty"`
+}
+
+// HolidayClosure closes the attraction for a whole day. Date is either
+// "YYYY-MM-DD" for a one-off closure or "MM-DD" for one that repeats yearly.
+type HolidayClosure struct {
+	Date string `json:"date"`
+	Name string `json:"name,omitempty"`
+}
+
+type OpeningHours struct {
+	Weekly   WeeklyHours      `json:"weekly"`
+	Seasons  []SeasonalHours  `json:"seasons,omitempty"`
+	Closures []HolidayClosure `json:"closures,omitempty"`
+}
+
+func (h OpeningHours) Value() (driver.Value, error) {
+	return json.Marshal(h)
+}
+
+func (h *OpeningHours) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into OpeningHours", value)
+	}
+	return json.Unmarshal(data, h)
+}
+
+// parseClock converts "HH:MM" to minutes since midnight.
+func parseClock(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// dayOfYear maps "MM-DD" onto a leap year so that Feb 29 is representable.
+func dayOfYear(value string) (int, error) {
+	t, err := time.Parse("2006-01-02", "2024-"+value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q (expected MM-DD)", value)
+	}
+	return t.YearDay(), nil
+}
+
+func (w WeeklyHours) validate() error {
+	for day, intervals := range w {
+		if _, ok := weekdayKeys[day]; !ok {
+			return fmt.Errorf("unknown weekday %q (expected mon, tue, wed, thu, fri, sat or sun)", day)
+		}
+
+		type span struct{ start, end int }
+		spans := make([]span, 0, len(intervals))
+		for _, interval := range intervals {
+			opens, err := parseClock(interval.Opens)
+			if err != nil {
+				return fmt.Errorf("%s: %w", day, err)
+			}
+			closes, err := parseClock(interval.Closes)
+			if err != nil {
+				return fmt.Errorf("%s: %w", day, err)
+			}
+			if closes <= opens {
+				return fmt.Errorf("%s: interval %s-%s must close after it opens", day, interval.Opens, interval.Closes)
+			}
+			spans = append(spans, span{opens, closes})
+		}
+
+		sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+		for i := 1; i < len(spans); i++ {
+			if spans[i].start < spans[i-1].end {
+				return fmt.Errorf("%s: opening intervals overlap", day)
+			}
+		}
+	}
+	return nil
+}
+
+// seasonDays returns the days of year covered by a season.
+func (s SeasonalHours) seasonDays() ([]int, error) {
+	from, err := dayOfYear(s.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := dayOfYear(s.To)
+	if err != nil {
+		return nil, err
+	}
+
+	var days []int
+	for day := from; ; day = day%366 + 1 {
+		days = append(days, day)
+		if day == to {
+			break
+		}
+	}
+	return days, nil
+}
+
+// Validate rejects malformed times and dates, overlapping intervals within a
+// day and seasons that overlap each other.
+func (h *OpeningHours) Validate() error {
+	if err := h.Weekly.validate(); err != nil {
+		return err
+	}
+
+	covered := make(map[int]string)
+	for _, season := range h.Seasons {
+		days, err := season.seasonDays()
+		if err != nil {
+			return fmt.Errorf("season %q: %w", season.Name, err)
+		}
+		for _, day := range days {
+			if other, ok := covered[day]; ok {
+				return fmt.Errorf("seasons %q and %q overlap", other, season.Name)
+			}
+			covered[day] = season.Name
+		}
+		if !season.Closed {
+			if err := season.Weekly.validate(); err != nil {
+				return fmt.Errorf("season %q: %w", season.Name, err)
+			}
+		}
+	}
+
+	for _, closure := range h.Closures {
+		if _, err := time.Parse("2006-01-02", closure.Date); err == nil {
+			continue
+		}
+		if _, err := dayOfYear(closure.Date); err != nil {
+			return errors.New("closure date must be YYYY-MM-DD or MM-DD")
+		}
+	}
+	return nil
+}
+
+func (s SeasonalHours) contains(t time.Time) bool {
+	from, errFrom := dayOfYear(s.From)
+	to, errTo := dayOfYear(s.To)
+	day, errDay := dayOfYear(t.Format("01-02"))
+	if errFrom != nil || errTo != nil || errDay != nil {
+		return false
+	}
+	if from <= to {
+		return day >= from && day <= to
+	}
+	return day >= from || day <= to
+}
+
+// IsOpenAt reports whether the attraction is open at t, evaluated in
+// Asia/Almaty.
+func (h *OpeningHours) IsOpenAt(t time.Time) bool {
+	local := t.In(Almaty)
+
+	for _, closure := range h.Closures {
+		if closure.Date == local.Format("2006-01-02") || closure.Date == local.Format("01-02") {
+			return false
+		}
+	}
+
+	weekly := h.Weekly
+	for _, season := range h.Seasons {
+		if season.contains(local) {
+			if season.Closed {
+				return false
+			}
+			weekly = season.Weekly
+			break
+		}
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	for day, intervals := range weekly {
+		if weekdayKeys[day] != local.Weekday() {
+			continue
+		}
+		for _, interval := range intervals {
+			opens, errOpens := parseClock(interval.Opens)
+			closes, errCloses := parseClock(interval.Closes)
+			if errOpens == nil && errCloses == nil && minute >= opens && minute < closes {
+				return true
+			}
+		}
+	}
+	return false
+}
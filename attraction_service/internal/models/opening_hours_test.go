@@ -0,0 +1,132 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func almaty(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, Almaty)
+}
+
+func TestIsOpenAt(t *testing.T) {
+	hours := OpeningHours{
+		Weekly: WeeklyHours{
+			"mon": {{Opens: "09:00", Closes: "13:00"}, {Opens: "14:00", Closes: "18:00"}},
+			"tue": {{Opens: "09:00", Closes: "18:00"}},
+			"fri": {{Opens: "18:00", Closes: "24:00"}},
+			"sat": {{Opens: "00:00", Closes: "02:00"}, {Opens: "10:00", Closes: "24:00"}},
+			"sun": {{Opens: "00:00", Closes: "02:00"}},
+		},
+		Seasons: []SeasonalHours{
+			{Name: "summer", From: "06-01", To: "08-31", Weekly: WeeklyHours{
+				"mon": {{Opens: "08:00", Closes: "22:00"}},
+			}},
+			{Name: "winter", From: "12-15", To: "01-15", Closed: true},
+		},
+		Closures: []HolidayClosure{
+			{Date: "03-22", Name: "Nauryz"},
+			{Date: "2025-05-05"},
+		},
+	}
+	if err := hours.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"monday morning", almaty(2025, 3, 3, 10, 0), true},
+		{"monday lunch break", almaty(2025, 3, 3, 13, 30), false},
+		{"opening minute", almaty(2025, 3, 3, 9, 0), true},
+		{"closing minute", almaty(2025, 3, 3, 18, 0), false},
+		{"closed weekday", almaty(2025, 3, 5, 12, 0), false},
+		{"friday night before midnight", almaty(2025, 3, 7, 23, 59), true},
+		{"overnight after midnight", almaty(2025, 3, 8, 1, 30), true},
+		{"overnight closed", almaty(2025, 3, 8, 2, 0), false},
+		{"saturday night into sunday", almaty(2025, 3, 9, 0, 30), true},
+		{"sunday night into monday", almaty(2025, 3, 10, 0, 30), false},
+		{"evaluated in almaty", time.Date(2025, 3, 3, 4, 0, 0, 0, time.UTC), true},
+		{"friday evening in utc is saturday in almaty", time.Date(2025, 3, 7, 21, 30, 0, 0, time.UTC), false},
+		{"yearly closure", almaty(2027, 3, 22, 10, 0), false},
+		{"one-off closure", almaty(2025, 5, 5, 10, 0), false},
+		{"one-off closure in another year", almaty(2026, 5, 5, 10, 0), true},
+		{"summer hours", almaty(2025, 6, 2, 20, 0), true},
+		{"summer replaces the week", almaty(2025, 6, 3, 10, 0), false},
+		{"first day of summer", almaty(2025, 6, 1, 1, 0), false},
+		{"last day of summer", almaty(2025, 8, 25, 21, 0), true},
+		{"after summer", almaty(2025, 9, 1, 20, 0), false},
+		{"winter closed in december", almaty(2025, 12, 16, 10, 0), false},
+		{"winter closed in january", almaty(2026, 1, 13, 10, 0), false},
+		{"after winter", almaty(2026, 1, 20, 10, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hours.IsOpenAt(tt.t); got != tt.want {
+				t.Errorf("IsOpenAt(%s) = %v, want %v", tt.t.In(Almaty).Format("Mon 2006-01-02 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeasonOnLeapDay(t *testing.T) {
+	hours := OpeningHours{
+		Weekly: WeeklyHours{"thu": {{Opens: "10:00", Closes: "18:00"}}},
+		Seasons: []SeasonalHours{
+			{Name: "end of february", From: "02-29", To: "03-01", Closed: true},
+		},
+	}
+	if hours.IsOpenAt(almaty(2024, 2, 29, 12, 0)) {
+		t.Error("open on Feb 29 during a closed season")
+	}
+	if !hours.IsOpenAt(almaty(2024, 2, 22, 12, 0)) {
+		t.Error("closed on a regular Thursday")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hours   OpeningHours
+		wantErr bool
+	}{
+		{"empty", OpeningHours{}, false},
+		{"until midnight", OpeningHours{Weekly: WeeklyHours{"fri": {{Opens: "18:00", Closes: "24:00"}}}}, false},
+		{"overnight interval", OpeningHours{Weekly: WeeklyHours{"fri": {{Opens: "22:00", Closes: "02:00"}}}}, true},
+		{"empty interval", OpeningHours{Weekly: WeeklyHours{"fri": {{Opens: "10:00", Closes: "10:00"}}}}, true},
+		{"bad time", OpeningHours{Weekly: WeeklyHours{"fri": {{Opens: "9am", Closes: "18:00"}}}}, true},
+		{"unknown weekday", OpeningHours{Weekly: WeeklyHours{"friday": {{Opens: "09:00", Closes: "18:00"}}}}, true},
+		{"overlapping intervals", OpeningHours{Weekly: WeeklyHours{
+			"mon": {{Opens: "09:00", Closes: "13:00"}, {Opens: "12:00", Closes: "18:00"}},
+		}}, true},
+		{"adjacent intervals", OpeningHours{Weekly: WeeklyHours{
+			"mon": {{Opens: "09:00", Closes: "13:00"}, {Opens: "13:00", Closes: "18:00"}},
+		}}, false},
+		{"season wrapping the new year", OpeningHours{Seasons: []SeasonalHours{
+			{Name: "winter", From: "11-01", To: "03-31", Closed: true},
+			{Name: "summer", From: "04-01", To: "10-31", Closed: true},
+		}}, false},
+		{"overlapping seasons", OpeningHours{Seasons: []SeasonalHours{
+			{Name: "winter", From: "11-01", To: "03-31", Closed: true},
+			{Name: "spring", From: "03-31", To: "05-31", Closed: true},
+		}}, true},
+		{"bad season date", OpeningHours{Seasons: []SeasonalHours{
+			{Name: "winter", From: "13-01", To: "03-31", Closed: true},
+		}}, true},
+		{"bad seasonal hours", OpeningHours{Seasons: []SeasonalHours{
+			{Name: "summer", From: "06-01", To: "08-31", Weekly: WeeklyHours{"mon": {{Opens: "22:00", Closes: "01:00"}}}},
+		}}, true},
+		{"closure dates", OpeningHours{Closures: []HolidayClosure{{Date: "01-01"}, {Date: "2025-05-09"}}}, false},
+		{"bad closure date", OpeningHours{Closures: []HolidayClosure{{Date: "2025/05/09"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hours.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
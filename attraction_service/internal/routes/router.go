@@ -3,34 +3,47 @@ package routes
 import (
 	"diplomaPorject/backend/attraction/internal/controllers"
 	"diplomaPorject/backend/attraction/internal/middleware"
+	"diplomaPorject/backend/shared/access"
 	"github.com/gorilla/mux"
-	"log"
-	"net/http"
 )
 
 func SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
 
-	uploadDir := "/app/uploads" // Must match Docker volume mount
+	// Each admin route checks the permission it needs; AdminAuthMiddleware
+	// only establishes that the caller is an admin.
+	can := access.RequirePermission
 
-	log.Printf("Serving static files from: %s", uploadDir)
-
-	// ✅ Serve static files under /uploads/
-	r.PathPrefix("/uploads/").Handler(
-		http.StripPrefix("/uploads/", http.FileServer(http.Dir(uploadDir))),
-	).Methods("GET")
-
-	// Attraction routes
+	// Attraction routes. Changes to an attraction go through OwnerOnly, which
+	// applies the edit policy.
 	admin := r.PathPrefix("/admin/attractions").Subrouter()
-	admin.Use(middleware.AdminAuthMiddleware)
-	admin.HandleFunc("", controllers.CreateAttraction).Methods("POST")
-	admin.HandleFunc("", controllers.ListAttractions).Methods("GET")
-	admin.HandleFunc("/{id}", controllers.GetAttraction).Methods("GET")
-	admin.HandleFunc("/{id}", controllers.UpdateAttraction).Methods("PUT")
-	admin.HandleFunc("/{id}", controllers.DeleteAttraction).Methods("DELETE")
-	admin.HandleFunc("/{id}/publish", controllers.PublishAttraction).Methods("POST")
-	admin.HandleFunc("/{id}/unpublish", controllers.UnpublishAttraction).Methods("POST")
+	admin.Use(access.AdminAuthMiddleware)
+	admin.HandleFunc("", can(middleware.PermAttractionsEdit, controllers.CreateAttraction)).Methods("POST")
+	admin.HandleFunc("", can(middleware.PermAttractionsView, controllers.ListAttractions)).Methods("GET")
+	// Registered before "/{id}" so that these paths are not taken for an attraction ID.
+	admin.HandleFunc("/import", can(middleware.PermAttractionsEdit, controllers.ImportAttractions)).Methods("POST")
+	admin.HandleFunc("/export", can(middleware.PermAttractionsView, controllers.ExportAttractionsForImport)).Methods("GET")
+	admin.HandleFunc("/{id}", can(middleware.PermAttractionsView, controllers.GetAttraction)).Methods("GET")
+	admin.HandleFunc("/{id}", can(middleware.PermAttractionsEdit, controllers.OwnerOnly(controllers.UpdateAttraction))).Methods("PUT")
+	admin.HandleFunc("/{id}", can(middleware.PermAttractionsEdit, controllers.OwnerOnly(controllers.PatchAttraction))).Methods("PATCH")
+	admin.HandleFunc("/{id}", can(middleware.PermAttractionsEdit, controllers.OwnerOnly(controllers.DeleteAttraction))).Methods("DELETE")
+	admin.HandleFunc("/{id}/publish", can(middleware.PermAttractionsPublish, controllers.OwnerOnly(controllers.PublishAttraction))).Methods("POST")
+	admin.HandleFunc("/{id}/unpublish", can(middleware.PermAttractionsPublish, controllers.OwnerOnly(controllers.UnpublishAttraction))).Methods("POST")
+	admin.HandleFunc("/{id}/schedule", can(middleware.PermAttractionsPublish, controllers.OwnerOnly(controllers.ScheduleAttraction))).Methods("PUT")
+	admin.HandleFunc("/{id}/images", can(middleware.PermAttractionsView, controllers.AttractionImages.List)).Methods("GET")
+	admin.HandleFunc("/{id}/images", can(middleware.PermAttractionsEdit, controllers.OwnerOnly(controllers.AttractionImages.Add))).Methods("POST")
+	admin.HandleFunc("/{id}/images/order", can(middleware.PermAttractionsEdit, controllers.OwnerOnly(controllers.AttractionImages.Reorder))).Methods("PUT")
+	admin.HandleFunc("/{id}/images/{imageId:[0-9]+}", can(middleware.PermAttractionsEdit, controllers.OwnerOnly(controllers.AttractionImages.UpdateImage))).Methods("PUT")
+	admin.HandleFunc("/{id}/images/{imageId:[0-9]+}", can(middleware.PermAttractionsEdit, controllers.OwnerOnly(controllers.AttractionImages.DeleteImage))).Methods("DELETE")
+	admin.HandleFunc("/{id}/images/{imageId:[0-9]+}/cover", can(middleware.PermAttractionsEdit, controllers.OwnerOnly(controllers.AttractionImages.SetCover))).Methods("POST")
+
+	audit := r.PathPrefix("/admin/audit/attractions").Subrouter()
+	audit.Use(access.AdminAuthMiddleware)
+	audit.HandleFunc("", can(access.PermAuditRead, controllers.ListAuditEntries)).Methods("GET")
 
 	r.HandleFunc("/attractions", controllers.ListPublishedAttractions).Methods("GET")
+	r.HandleFunc("/attractions/nearby", controllers.ListNearbyAttractions).Methods("GET")
+	r.HandleFunc("/attractions/export.{format:geojson|kml|gpx}", controllers.ExportAttractions).Methods("GET")
+	r.HandleFunc("/attractions/{id:[0-9]+}", controllers.GetPublishedAttraction).Methods("GET")
 	return r
 }
@@ -0,0 +1,117 @@
+// Package scheduler applies scheduled publish and unpublish times.
+package scheduler
+
+import (
+	"context"
+	"diplomaPorject/backend/attraction/internal/models"
+	"diplomaPorject/backend/attraction/utils/db"
+	"diplomaPorject/backend/shared/audit"
+	"gorm.io/gorm"
+	"log"
+	"os"
+	"time"
+)
+
+// lockKey is the Postgres advisory lock held while a run is in progress, so
+// that only one replica applies schedules at a time. The services share a
+// database, so each uses its own key.
+const lockKey int64 = 0x54524b5a0002
+
+const defaultInterval = time.Minute
+
+func interval() time.Duration {
+	value := os.Getenv("SCHEDULER_INTERVAL")
+	if value == "" {
+		return defaultInterval
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid SCHEDULER_INTERVAL %q, using %s", value, defaultInterval)
+		return defaultInterval
+	}
+	return d
+}
+
+// Start runs the scheduler in the background until ctx is cancelled.
+func Start(ctx context.Context) {
+	every := interval()
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			if err := RunOnce(time.Now()); err != nil {
+				log.Printf("Scheduler run failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Scheduler running every %s", every)
+}
+
+// RunOnce applies everything due at now. It does nothing if another replica
+// holds the lock; the lock is released when the transaction ends.
+func RunOnce(now time.Time) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", lockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var attractions []models.Attraction
+		if err := tx.Where("publish_at <= ? OR unpublish_at <= ?", now, now).Find(&attractions).Error; err != nil {
+			return err
+		}
+
+		for _, attraction := range attractions {
+			publishDue := attraction.PublishAt != nil && !attraction.PublishAt.After(now)
+			unpublishDue := attraction.UnpublishAt != nil && !attraction.UnpublishAt.After(now)
+
+			// When both came due since the last run, the later one wins.
+			published := attraction.IsPublished
+			switch {
+			case publishDue && unpublishDue:
+				published = attraction.PublishAt.After(*attraction.UnpublishAt)
+			case publishDue:
+				published = true
+			case unpublishDue:
+				published = false
+			}
+
+			before, err := audit.Snapshot(&attraction)
+			if err != nil {
+				return err
+			}
+
+			attraction.IsPublished = published
+			updates := map[string]interface{}{"is_published": published}
+			if publishDue {
+				attraction.PublishAt = nil
+				updates["publish_at"] = nil
+			}
+			if unpublishDue {
+				attraction.UnpublishAt = nil
+				updates["unpublish_at"] = nil
+			}
+			if err := tx.Model(&attraction).Updates(updates).Error; err != nil {
+				return err
+			}
+
+			action := audit.ActionUnpublish
+			if published {
+				action = audit.ActionPublish
+			}
+			if err := models.AttractionAudit.Record(tx, audit.SystemAdmin, action, attraction.ID, before, &attraction); err != nil {
+				return err
+			}
+			log.Printf("Scheduler set attraction %d published=%t", attraction.ID, published)
+		}
+		return nil
+	})
+}
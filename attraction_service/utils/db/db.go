@@ -19,7 +19,7 @@ func ConnectDB() {
 
 	DB = dbInstance
 
-	err = DB.AutoMigrate(&models.Attraction{})
+	err = DB.AutoMigrate(&models.Attraction{}, &models.AttractionImage{}, &models.AuditEntry{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
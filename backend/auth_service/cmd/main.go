@@ -3,6 +3,8 @@ package main
 import (
 	"authorization_service/internal/routes"
 	"authorization_service/utils/db"
+	"authorization_service/utils/mail"
+	utils "authorization_service/utils/session"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +17,12 @@ func main() {
 		log.Fatal("Database connection is nil!")
 	}
 
+	if err := mail.Setup(); err != nil {
+		log.Fatalf("Failed to set up mail: %v", err)
+	}
+
+	utils.StartCleanup()
+
 	r := routes.SetupRoutes()
 
 	fmt.Println("Server running on port:", 8082)
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"authorization_service/internal/model"
+	"authorization_service/utils/db"
+	"authorization_service/utils/hashing"
+	"authorization_service/utils/mail"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	minPasswordLength = 8
+
+	// mailInterval is the least time between two emails of the same kind to
+	// one user, so the endpoints cannot be used to flood an inbox.
+	mailInterval = time.Minute
+)
+
+var ErrWeakPassword = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+
+// appBaseURL is where the links in emails point. The frontend handles the
+// pages and posts the token back to this service.
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return base
+	}
+	return "http://localhost:8080"
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// recentlyMailed reports whether a token of purpose was sent to the user
+// within mailInterval.
+func recentlyMailed(userID uint, purpose string) (bool, error) {
+	var count int64
+	err := db.DB.Model(&model.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, time.Now().Add(-mailInterval)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func sendVerificationEmail(user *model.User, token string) {
+	link := appBaseURL() + "/verify-email?token=" + url.QueryEscape(token)
+	err := mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\n"+
+			"The link is valid for %d hours.\n", user.Username, link, int(verifyEmailTTL.Hours())),
+	})
+	if err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+}
+
+func sendPasswordResetEmail(user *model.User, token string) {
+	link := appBaseURL() + "/reset-password?token=" + url.QueryEscape(token)
+	err := mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. "+
+			"To choose a new password, open this link:\n\n%s\n\n"+
+			"The link is valid for %d minutes. If you did not ask for this, you can ignore this email.\n",
+			user.Username, link, int(resetPasswordTTL.Minutes())),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+}
+
+func writeTokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidToken):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Token redemption failed: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+	}
+}
+
+// VerifyEmail confirms the address of the user the token was sent to.
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeToken(tx, req.Token, model.TokenVerifyEmail)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.User{}).Where("id = ? AND email_verified_at IS NULL", userToken.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email verified"})
+}
+
+// ResendVerification sends a new verification email to the logged-in user.
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if user.EmailVerified() {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	recent, err := recentlyMailed(user.ID, model.TokenVerifyEmail)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if recent {
+		w.Header().Set("Retry-After", fmt.Sprint(int(mailInterval.Seconds())))
+		http.Error(w, "A verification email was sent recently, please wait before asking again", http.StatusTooManyRequests)
+		return
+	}
+
+	token, err := issueToken(db.DB, user.ID, model.TokenVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	sendVerificationEmail(&user, token)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+}
+
+// ForgotPassword emails a password reset link. It answers the same way
+// whether or not the address belongs to an account, so it cannot be used to
+// find out who is registered.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var user model.User
+	err := db.DB.Where("lower(email) = ?", model.NormalizeEmail(req.Email)).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	default:
+		recent, err := recentlyMailed(user.ID, model.TokenResetPassword)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if recent {
+			break
+		}
+		token, err := issueToken(db.DB, user.ID, model.TokenResetPassword, resetPasswordTTL)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		sendPasswordResetEmail(&user, token)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "If the address is registered, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. Every
+// session of the user is ended, and the address counts as verified since the
+// user read the email.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := hashing.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Error with hashing password", http.StatusInternalServerError)
+		return
+	}
+
+	var userID uint
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeToken(tx, req.Token, model.TokenResetPassword)
+		if err != nil {
+			return err
+		}
+		userID = userToken.UserID
+		if err := tx.Model(&model.User{}).Where("id = ?", userToken.UserID).
+			Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.User{}).Where("id = ? AND email_verified_at IS NULL", userToken.UserID).
+			Update("email_verified_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userToken.UserID).Delete(&model.Session{}).Error
+	})
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	recordAuthEvent(r, authEvent{Event: EventPasswordReset, UserID: userID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password has been reset"})
+}
+
+// ChangePassword sets a new password for the logged-in user after checking
+// the current one. Every other session of the user is ended.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err := hashing.CheckPassword(user.Password, req.CurrentPassword); err != nil {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	hashedPassword, err := hashing.HashPassword(req.NewPassword)
+	if err != nil {
+		http.Error(w, "Error with hashing password", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, _ := r.Context().Value("session_id").(uint)
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND id <> ?", user.ID, sessionID).Delete(&model.Session{}).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	recordAuthEvent(r, authEvent{Event: EventPasswordChanged, UserID: user.ID, Email: user.Email})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password changed, other sessions have been logged out"})
+}
@@ -2,31 +2,62 @@ package controllers
 
 import (
 	"authorization_service/internal/model"
+	"authorization_service/internal/rbac"
 	"authorization_service/utils/db"
 	"authorization_service/utils/hashing"
 	utils "authorization_service/utils/session"
+	"authorization_service/utils/totp"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"gorm.io/gorm"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Register creates an account and emails a verification link. The account
+// can log in at once but stays limited until the address is confirmed.
 func Register(w http.ResponseWriter, r *http.Request) {
 
 	var creds struct {
 		Username string `json:"username"`
-		Email    string `json:"'email'"`
+		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&creds)
 	if err != nil {
-		http.Error(w, "Error with decoding", http.StatusInternalServerError)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	creds.Email = model.NormalizeEmail(creds.Email)
+	if creds.Username == "" || !strings.Contains(creds.Email, "@") {
+		http.Error(w, "A username and a valid email are required", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(creds.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	hashedPassword, err := hashing.HashPassword(creds.Password)
 	if err != nil {
 		http.Error(w, "Error with hashing password", http.StatusInternalServerError)
+		return
+	}
+
+	var taken int64
+	if err := db.DB.Model(&model.User{}).Where("lower(email) = ? OR username = ?", creds.Email, creds.Username).
+		Count(&taken).Error; err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if taken > 0 {
+		http.Error(w, "Username or email is already registered", http.StatusConflict)
+		return
 	}
 
 	user := model.User{
@@ -35,13 +66,27 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		Password: hashedPassword,
 	}
 
-	if err := db.DB.Create(&user).Error; err != nil {
+	var token string
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		token, err = issueToken(tx, user.ID, model.TokenVerifyEmail, verifyEmailTTL)
+		return err
+	})
+	if err != nil {
 		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		return
 	}
+	sendVerificationEmail(&user, token)
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully, check your email to verify your address"})
 }
+
+// Login checks the password. Users with two-factor authentication get a
+// challenge token instead of a session and finish with LoginTwoFactor.
 func Login(w http.ResponseWriter, r *http.Request) {
 	var creds struct {
 		Email    string `json:"email"`
@@ -53,32 +98,109 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user model.User
+	creds.Email = model.NormalizeEmail(creds.Email)
 
-	if err := db.DB.Where("email=?", creds.Email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
-		} else {
-			http.Error(w, "Database error", http.StatusInternalServerError)
-		}
+	now := time.Now()
+	ip := utils.ClientIP(r)
+	accountKey, ipKey := accountThrottleKey(creds.Email), ipThrottleKey(ip)
+
+	// Throttling is keyed by the email as typed, so unknown emails are
+	// slowed down exactly like real accounts.
+	wait, err := accountThrottle.retryAfter(accountKey, now)
+	if err == nil {
+		var ipWait time.Duration
+		ipWait, err = ipThrottle.retryAfter(ipKey, now)
+		wait = max(wait, ipWait)
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
 		return
 	}
 
-	if err := hashing.CheckPassword(user.Password, creds.Password); err != nil {
+	// Unknown emails and wrong passwords take the same path: one bcrypt
+	// comparison and one failure record, so their timing is the same.
+	var user model.User
+	err = db.DB.Where("lower(email) = ?", creds.Email).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		hashing.CheckDummyPassword(creds.Password)
+		err = ErrWrongPassword
+	case err != nil:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	default:
+		if hashing.CheckPassword(user.Password, creds.Password) != nil {
+			err = ErrWrongPassword
+		}
+	}
+	if err != nil {
+		recordLoginFailure(r, &user, creds.Email, accountKey, ipKey, now)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	if err := utils.CreateSession(w, r, user.ID); err != nil {
+	// With two-factor authentication the password only earns a challenge,
+	// which LoginTwoFactor exchanges for a session together with a code.
+	// The failure count is kept until then, so guessing codes stays
+	// throttled.
+	if user.TOTPEnabled() {
+		challenge, err := issueToken(db.DB, user.ID, model.TokenLoginChallenge, loginChallengeTTL)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":             "Two-factor authentication required",
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+
+	if err := clearThrottle(accountKey); err != nil {
+		log.Printf("Failed to reset login throttle of user %d: %v", user.ID, err)
+	}
+	if err := utils.CreateSession(w, r, user.ID, false); err != nil {
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
+	recordAuthEvent(r, authEvent{Event: EventLoginSucceeded, UserID: user.ID, Email: user.Email})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message":"Login successful"}`))
 }
 
+// recordLoginFailure counts a failed login against the account and the
+// client address, and logs it along with any lock it caused. user is empty
+// when the email is unknown.
+func recordLoginFailure(r *http.Request, user *model.User, email, accountKey, ipKey string, now time.Time) {
+	recordAuthEvent(r, authEvent{Event: EventLoginFailed, UserID: user.ID, Email: email})
+
+	locked, err := accountThrottle.recordFailure(accountKey, now)
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+	}
+	if locked {
+		recordAuthEvent(r, authEvent{Event: EventAccountLocked, UserID: user.ID, Email: email,
+			Detail: fmt.Sprintf("locked for %s", accountThrottle.LockFor)})
+	}
+
+	locked, err = ipThrottle.recordFailure(ipKey, now)
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+	}
+	if locked {
+		recordAuthEvent(r, authEvent{Event: EventIPLocked, Detail: fmt.Sprintf("locked for %s", ipThrottle.LockFor)})
+	}
+}
+
 func Logout(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("session_token")
 	if err != nil {
@@ -108,7 +230,7 @@ func GetProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user model.User
-	if err := db.DB.First(&user, userID).Error; err != nil {
+	if err := db.DB.Preload("Roles").First(&user, userID).Error; err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
@@ -116,51 +238,69 @@ func GetProfile(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// ValidateSession tells other services who the caller is and what they
+// may do.
 func ValidateSession(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
+	session, ok := utils.GetSession(r)
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var session model.Session
-	if err := db.DB.Where("token = ? AND expires_at > ?",
-		cookie.Value, time.Now()).First(&session).Error; err != nil {
+	var user model.User
+	if err := db.DB.Preload("Roles").First(&user, session.UserID).Error; err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
+	roles := roleNames(&user)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]uint{
-		"user_id": session.UserID,
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_id":        session.UserID,
+		"email_verified": user.EmailVerified(),
+		"roles":          roles,
+		"permissions":    rbac.Permissions(roles),
 	})
 }
+
+// ValidateAdmin accepts any verified user holding at least one admin
+// permission. The calling service checks the permission its route needs.
 func ValidateAdmin(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
+	if _, err := r.Cookie("session_token"); err != nil {
 		http.Error(w, "No authorization token", http.StatusUnauthorized)
 		return
 	}
 
-	var session model.Session
-	if err := db.DB.Where("token = ?", cookie.Value).First(&session).Error; err != nil {
+	session, ok := utils.GetSession(r)
+	if !ok {
 		http.Error(w, "Unauthorized token", http.StatusUnauthorized)
 		return
 	}
 
 	var user model.User
-	if err := db.DB.First(&user, session.UserID).Error; err != nil {
+	if err := db.DB.Preload("Roles").First(&user, session.UserID).Error; err != nil {
 		http.Error(w, "User not found", http.StatusUnauthorized)
 		return
 	}
 
-	if !user.IsAdmin {
+	roles := roleNames(&user)
+	permissions := rbac.Permissions(roles)
+	if len(permissions) == 0 {
 		http.Error(w, "Forbidden", http.StatusUnauthorized) // Changed to 401
 		return
 	}
+	if !user.EmailVerified() {
+		http.Error(w, "Email address not verified", http.StatusForbidden)
+		return
+	}
+	if totp.RequiredForAdmins() && !session.TwoFactor {
+		http.Error(w, ErrTwoFactorRequired.Error(), http.StatusForbidden)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]uint{
-		"admin_id": user.ID,
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"admin_id":    user.ID,
+		"roles":       roles,
+		"permissions": permissions,
 	})
-	w.WriteHeader(http.StatusOK)
 }
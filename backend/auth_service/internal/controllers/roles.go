@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"authorization_service/internal/model"
+	"authorization_service/internal/rbac"
+	"authorization_service/utils/db"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+)
+
+var (
+	ErrUnknownRole     = errors.New("unknown role")
+	ErrLastSuperAdmin  = errors.New("the last super-admin cannot lose the role")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrRoleNotAssigned = errors.New("user does not have this role")
+)
+
+// roleNames returns the roles of a user loaded with Preload("Roles").
+func roleNames(user *model.User) []string {
+	names := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		names = append(names, role.Role)
+	}
+	return names
+}
+
+func writeRoleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrRoleNotAssigned):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrLastSuperAdmin):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+	}
+}
+
+// ListRoles returns every grantable role with its permissions.
+func ListRoles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rbac.Roles())
+}
+
+func writeUserRoles(w http.ResponseWriter, userID string) {
+	var user model.User
+	if err := db.DB.Preload("Roles").First(&user, userID).Error; err != nil {
+		writeRoleError(w, ErrUserNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_id":     user.ID,
+		"roles":       user.Roles,
+		"permissions": rbac.Permissions(roleNames(&user)),
+	})
+}
+
+func GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	writeUserRoles(w, mux.Vars(r)["id"])
+}
+
+// GrantRole gives a user a role. Granting a role the user already has is
+// not an error.
+func GrantRole(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !rbac.Valid(req.Role) {
+		writeRoleError(w, ErrUnknownRole)
+		return
+	}
+
+	var user model.User
+	if err := db.DB.First(&user, mux.Vars(r)["id"]).Error; err != nil {
+		writeRoleError(w, ErrUserNotFound)
+		return
+	}
+
+	grantedBy, _ := r.Context().Value("user_id").(uint)
+	result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.UserRole{UserID: user.ID, Role: req.Role, GrantedBy: grantedBy})
+	if result.Error != nil {
+		writeRoleError(w, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		recordAuthEvent(r, authEvent{Event: EventRoleGranted, UserID: user.ID, ActorID: grantedBy, Detail: req.Role})
+	}
+
+	writeUserRoles(w, mux.Vars(r)["id"])
+}
+
+// RevokeRole takes a role away from a user. The last super-admin keeps the
+// role, so there is always someone who can manage roles.
+func RevokeRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	role := vars["role"]
+	if !rbac.Valid(role) {
+		writeRoleError(w, ErrUnknownRole)
+		return
+	}
+
+	var userID uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var grant model.UserRole
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND role = ?", vars["id"], role).First(&grant).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleNotAssigned
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == rbac.RoleSuperAdmin {
+			// Lock every super-admin grant so two revocations cannot both
+			// pass the check.
+			var superAdmins []model.UserRole
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("role = ?", rbac.RoleSuperAdmin).Find(&superAdmins).Error; err != nil {
+				return err
+			}
+			if len(superAdmins) <= 1 {
+				return ErrLastSuperAdmin
+			}
+		}
+		if err := tx.Delete(&grant).Error; err != nil {
+			return err
+		}
+		userID = grant.UserID
+		return nil
+	})
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+	actorID, _ := r.Context().Value("user_id").(uint)
+	recordAuthEvent(r, authEvent{Event: EventRoleRevoked, UserID: userID, ActorID: actorID, Detail: role})
+
+	writeUserRoles(w, vars["id"])
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"authorization_service/internal/model"
+	"authorization_service/utils/db"
+	utils "authorization_service/utils/session"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// sessionView is a session as its owner sees it; the token is never shown.
+type sessionView struct {
+	ID         uint      `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+}
+
+func sessionIDFromContext(r *http.Request) uint {
+	sessionID, _ := r.Context().Value("session_id").(uint)
+	return sessionID
+}
+
+// ListSessions returns the active sessions of the logged-in user, most
+// recently used first.
+func ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+	currentID := sessionIDFromContext(r)
+
+	now := time.Now()
+	var sessions []model.Session
+	if err := db.DB.Where("user_id = ? AND expires_at > ? AND max_expires_at > ?", userID, now, now).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]sessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, sessionView{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			Current:    session.ID == currentID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// RevokeSession ends one of the user's sessions. Revoking the current
+// session logs the user out.
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+
+	var session model.Session
+	if err := db.DB.Where("user_id = ?", userID).First(&session, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err := db.DB.Delete(&session).Error; err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	if session.ID == sessionIDFromContext(r) {
+		utils.ClearCookie(w)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllSessions logs the user out everywhere. With keep_current=true
+// the session making the request stays active.
+func RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+
+	var keepID uint
+	if r.URL.Query().Get("keep_current") == "true" {
+		keepID = sessionIDFromContext(r)
+	}
+	if err := utils.RevokeUserSessions(userID, keepID); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	if keepID == 0 {
+		utils.ClearCookie(w)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
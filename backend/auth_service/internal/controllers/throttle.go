@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"authorization_service/internal/model"
+	"authorization_service/utils/db"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// throttlePolicy describes how failed logins for a key slow down further
+// attempts. After FreeFailures failures each attempt waits BaseDelay,
+// doubling per failure up to MaxDelay; at LockAfter failures the key is
+// locked for LockFor. Failures older than ResetAfter are forgotten.
+type throttlePolicy struct {
+	FreeFailures int
+	LockAfter    int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockFor      time.Duration
+	ResetAfter   time.Duration
+}
+
+var (
+	accountThrottle = throttlePolicy{
+		FreeFailures: 3,
+		LockAfter:    10,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockFor:      30 * time.Minute,
+		ResetAfter:   24 * time.Hour,
+	}
+	// Many users can share an address, so addresses get more room.
+	ipThrottle = throttlePolicy{
+		FreeFailures: 10,
+		LockAfter:    50,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockFor:      15 * time.Minute,
+		ResetAfter:   time.Hour,
+	}
+)
+
+func accountThrottleKey(email string) string {
+	return "account:" + model.NormalizeEmail(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// delay returns how long after the last failure the next attempt has to
+// wait.
+func (p throttlePolicy) delay(failures int) time.Duration {
+	if failures <= p.FreeFailures {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeFailures + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// retryAfter returns how long the key must wait before its next attempt,
+// or 0 if it may try now.
+func (p throttlePolicy) retryAfter(key string, now time.Time) (time.Duration, error) {
+	var throttle model.LoginThrottle
+	err := db.DB.Where("key = ?", key).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return p.wait(throttle, now), nil
+}
+
+// wait returns how long a key with the recorded failures must wait at now.
+func (p throttlePolicy) wait(throttle model.LoginThrottle, now time.Time) time.Duration {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now)
+	}
+	if now.Sub(throttle.LastFailureAt) > p.ResetAfter {
+		return 0
+	}
+	if wait := throttle.LastFailureAt.Add(p.delay(throttle.Failures)).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// lockedUntil returns when a key that has just failed for the failures-th
+// time may try again, or nil if the failure does not lock it.
+func (p throttlePolicy) lockedUntil(failures int, now time.Time) *time.Time {
+	if failures < p.LockAfter {
+		return nil
+	}
+	until := now.Add(p.LockFor)
+	return &until
+}
+
+// recordFailure counts a failed login and reports whether it locked the
+// key. The counter is updated in one statement, so concurrent failures are
+// all counted.
+func (p throttlePolicy) recordFailure(key string, now time.Time) (bool, error) {
+	var failures int
+	err := db.DB.Raw(`INSERT INTO login_throttles (key, failures, last_failure_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures`, key, now, now.Add(-p.ResetAfter)).Scan(&failures).Error
+	if err != nil {
+		return false, err
+	}
+	until := p.lockedUntil(failures, now)
+	if until == nil {
+		return false, nil
+	}
+
+	// After the lock the count restarts where backoff begins, so a key that
+	// keeps failing is locked again.
+	err = db.DB.Model(&model.LoginThrottle{}).Where("key = ?", key).Updates(map[string]interface{}{
+		"locked_until": *until,
+		"failures":     p.FreeFailures,
+	}).Error
+	return err == nil, err
+}
+
+// clearThrottle forgets the failures of a key, after a successful login or
+// an admin unlock.
+func clearThrottle(key string) error {
+	return db.DB.Where("key = ?", key).Delete(&model.LoginThrottle{}).Error
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"authorization_service/internal/model"
+	"testing"
+	"time"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{12, 256 * time.Second},
+		{13, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := accountThrottle.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	lockedUntil := now.Add(10 * time.Minute)
+	expiredLock := now.Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		throttle model.LoginThrottle
+		want     time.Duration
+	}{
+		{"free failures", model.LoginThrottle{Failures: 3, LastFailureAt: now}, 0},
+		{"backoff", model.LoginThrottle{Failures: 5, LastFailureAt: now.Add(-500 * time.Millisecond)}, 1500 * time.Millisecond},
+		{"backoff over", model.LoginThrottle{Failures: 5, LastFailureAt: now.Add(-3 * time.Second)}, 0},
+		{"locked", model.LoginThrottle{Failures: 3, LastFailureAt: now, LockedUntil: &lockedUntil}, 10 * time.Minute},
+		{"lock expired", model.LoginThrottle{Failures: 3, LastFailureAt: now.Add(-31 * time.Minute), LockedUntil: &expiredLock}, 0},
+		{"failures forgotten", model.LoginThrottle{Failures: 9, LastFailureAt: now.Add(-25 * time.Hour)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accountThrottle.wait(tt.throttle, now); got != tt.want {
+				t.Errorf("wait = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottleLockedUntil(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		policy   throttlePolicy
+		failures int
+		locked   bool
+	}{
+		{accountThrottle, 9, false},
+		{accountThrottle, 10, true},
+		{accountThrottle, 11, true},
+		{ipThrottle, 10, false},
+		{ipThrottle, 49, false},
+		{ipThrottle, 50, true},
+	}
+	for _, tt := range tests {
+		until := tt.policy.lockedUntil(tt.failures, now)
+		if (until != nil) != tt.locked {
+			t.Errorf("lockedUntil(%d) = %v, want locked %v", tt.failures, until, tt.locked)
+			continue
+		}
+		if until != nil && !until.Equal(now.Add(tt.policy.LockFor)) {
+			t.Errorf("lockedUntil(%d) = %v, want %v", tt.failures, until, now.Add(tt.policy.LockFor))
+		}
+	}
+}
+
+// TestThrottleSequence replays the failures of one account the way
+// recordFailure stores them and checks the resulting waits.
+func TestThrottleSequence(t *testing.T) {
+	p := accountThrottle
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	var throttle model.LoginThrottle
+
+	fail := func() bool {
+		if now.Sub(throttle.LastFailureAt) > p.ResetAfter {
+			throttle.Failures = 0
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		if until := p.lockedUntil(throttle.Failures, now); until != nil {
+			throttle.LockedUntil = until
+			throttle.Failures = p.FreeFailures
+			return true
+		}
+		return false
+	}
+
+	for i := 1; i < p.LockAfter; i++ {
+		if fail() {
+			t.Fatalf("failure %d locked the account", i)
+		}
+		if wait := p.wait(throttle, now); wait != p.delay(i) {
+			t.Fatalf("after failure %d: wait = %v, want %v", i, wait, p.delay(i))
+		}
+		now = now.Add(p.delay(i))
+		if wait := p.wait(throttle, now); wait != 0 {
+			t.Fatalf("after waiting out failure %d: wait = %v, want 0", i, wait)
+		}
+	}
+	if !fail() {
+		t.Fatalf("failure %d did not lock the account", p.LockAfter)
+	}
+	if wait := p.wait(throttle, now); wait != p.LockFor {
+		t.Fatalf("wait after lock = %v, want %v", wait, p.LockFor)
+	}
+
+	// Once the lock is over the next failure is back in backoff, not free.
+	now = now.Add(p.LockFor)
+	if wait := p.wait(throttle, now); wait != 0 {
+		t.Fatalf("wait after the lock ended = %v, want 0", wait)
+	}
+	fail()
+	if wait := p.wait(throttle, now); wait != p.BaseDelay {
+		t.Fatalf("wait after failing again = %v, want %v", wait, p.BaseDelay)
+	}
+}
+
+func TestAccountThrottleKey(t *testing.T) {
+	if a, b := accountThrottleKey(" User@Example.com "), accountThrottleKey("user@example.com"); a != b {
+		t.Errorf("keys differ by case or spacing: %q, %q", a, b)
+	}
+	if accountThrottleKey("a@example.com") == ipThrottleKey("a@example.com") {
+		t.Errorf("account and address keys collide")
+	}
+}
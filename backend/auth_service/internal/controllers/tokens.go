@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"authorization_service/internal/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	verifyEmailTTL   = 48 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+var ErrInvalidToken = errors.New("token is invalid or has expired")
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueToken creates a token for purpose, replacing any earlier unused
+// token of the same purpose, so only the latest email works.
+func issueToken(tx *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Delete(&model.UserToken{}).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Create(&model.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// lockToken finds an unused, unexpired token and locks it for the rest of
+// the transaction.
+func lockToken(tx *gorm.DB, token, purpose string) (*model.UserToken, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var userToken model.UserToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).
+		First(&userToken).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userToken.UsedAt != nil || !time.Now().Before(userToken.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return &userToken, nil
+}
+
+func markTokenUsed(tx *gorm.DB, userToken *model.UserToken) error {
+	now := time.Now()
+	userToken.UsedAt = &now
+	return tx.Model(userToken).Update("used_at", now).Error
+}
+
+// consumeToken redeems a token, marking it used so it cannot be redeemed
+// again. It must run in a transaction together with the change the token
+// allows.
+func consumeToken(tx *gorm.DB, token, purpose string) (*model.UserToken, error) {
+	userToken, err := lockToken(tx, token, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if err := markTokenUsed(tx, userToken); err != nil {
+		return nil, err
+	}
+	return userToken, nil
+}
@@ -0,0 +1,423 @@
+package controllers
+
+import (
+	"authorization_service/internal/model"
+	"authorization_service/utils/db"
+	"authorization_service/utils/hashing"
+	utils "authorization_service/utils/session"
+	"authorization_service/utils/totp"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	loginChallengeTTL = 5 * time.Minute
+	// maxChallengeAttempts wrong codes end a login challenge, and the user
+	// has to enter the password again.
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+var (
+	ErrInvalidCode         = errors.New("invalid authentication code")
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotStarted = errors.New("start two-factor setup first")
+	ErrWrongPassword       = errors.New("password is incorrect")
+	ErrTwoFactorRequired   = errors.New("admin access requires logging in with two-factor authentication")
+	ErrTooManyAttempts     = errors.New("too many failed login attempts, try again later")
+)
+
+// secondFactor is the code a user sends: either a TOTP code or one of the
+// recovery codes.
+type secondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidCode), errors.Is(err, ErrInvalidToken), errors.Is(err, ErrWrongPassword):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrTwoFactorEnabled), errors.Is(err, ErrTwoFactorNotEnabled), errors.Is(err, ErrTwoFactorNotStarted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrTooManyAttempts):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		log.Printf("Two-factor request failed: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+	}
+}
+
+func lockUser(tx *gorm.DB, id uint) (*model.User, error) {
+	var user model.User
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// normalizeRecoveryCode accepts codes with any case, spaces or dashes.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// newRecoveryCodes replaces the user's recovery codes. The codes are
+// returned once and only their hashes are kept.
+func newRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(raw))[:10]
+		if err := tx.Create(&model.RecoveryCode{UserID: userID, CodeHash: hashToken(code)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// checkSecondFactor verifies a TOTP or recovery code of a user locked in
+// tx. A TOTP code is remembered so it cannot be used again; a recovery code
+// is used up.
+func checkSecondFactor(tx *gorm.DB, user *model.User, factor secondFactor) error {
+	if factor.Code != "" {
+		counter, ok := totp.Validate(user.TOTPSecret, factor.Code, time.Now(), user.TOTPLastCounter)
+		if !ok {
+			return ErrInvalidCode
+		}
+		user.TOTPLastCounter = counter
+		return tx.Model(user).Update("totp_last_counter", counter).Error
+	}
+
+	if factor.RecoveryCode == "" {
+		return ErrInvalidCode
+	}
+	result := tx.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(factor.RecoveryCode))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// TwoFactorStatus tells the logged-in user whether two-factor
+// authentication is on and how many recovery codes are left.
+func TwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		writeTwoFactorError(w, ErrUserNotFound)
+		return
+	}
+	var remaining int64
+	if err := db.DB.Model(&model.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&remaining).Error; err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":                  user.TOTPEnabled(),
+		"enabled_at":               user.TOTPEnabledAt,
+		"recovery_codes_remaining": remaining,
+		"required_for_admins":      totp.RequiredForAdmins(),
+	})
+}
+
+// SetupTwoFactor starts enrolment with a new secret. The secret has no
+// effect until ConfirmTwoFactor receives a code generated from it.
+func SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	var user *model.User
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TOTPEnabled() {
+			return ErrTwoFactorEnabled
+		}
+		return tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":       secret,
+			"totp_last_counter": 0,
+		}).Error
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor turns two-factor authentication on once the user proves
+// their app works. It returns the recovery codes, which are not shown
+// again. The current session counts as two-factor from now on and every
+// other session is logged out.
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+	sessionID, _ := r.Context().Value("session_id").(uint)
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var codes []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TOTPEnabled() {
+			return ErrTwoFactorEnabled
+		}
+		if user.TOTPSecret == "" {
+			return ErrTwoFactorNotStarted
+		}
+		if err := checkSecondFactor(tx, user, secondFactor{Code: req.Code}); err != nil {
+			return err
+		}
+
+		if err := tx.Model(user).Update("totp_enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if codes, err = newRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Session{}).Where("id = ?", sessionID).Update("two_factor", true).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND id <> ?", user.ID, sessionID).Delete(&model.Session{}).Error
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	recordAuthEvent(r, authEvent{Event: EventTwoFactorEnabled, UserID: userID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns two-factor authentication off. It needs the
+// password and a current code, so a stolen session alone cannot do it.
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+
+	var req struct {
+		Password string `json:"password"`
+		secondFactor
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.TOTPEnabled() {
+			return ErrTwoFactorNotEnabled
+		}
+		if err := hashing.CheckPassword(user.Password, req.Password); err != nil {
+			return ErrWrongPassword
+		}
+		if err := checkSecondFactor(tx, user, req.secondFactor); err != nil {
+			return err
+		}
+
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":       "",
+			"totp_enabled_at":   nil,
+			"totp_last_counter": 0,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Session{}).Where("user_id = ?", user.ID).Update("two_factor", false).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&model.RecoveryCode{}).Error
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	recordAuthEvent(r, authEvent{Event: EventTwoFactorDisabled, UserID: userID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a TOTP
+// code.
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(uint)
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var codes []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.TOTPEnabled() {
+			return ErrTwoFactorNotEnabled
+		}
+		if err := checkSecondFactor(tx, user, secondFactor{Code: req.Code}); err != nil {
+			return err
+		}
+		codes, err = newRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// LoginTwoFactor is the second step of Login: it exchanges the challenge
+// token and a TOTP or recovery code for a session.
+func LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token"`
+		secondFactor
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// A wrong code is counted in the same transaction, so the transaction
+	// has to commit; the outcome is reported through codeErr.
+	var user *model.User
+	var codeErr error
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		challenge, err := lockToken(tx, req.ChallengeToken, model.TokenLoginChallenge)
+		if err != nil {
+			return err
+		}
+		user, err = lockUser(tx, challenge.UserID)
+		if err != nil {
+			return err
+		}
+		wait, err := accountThrottle.retryAfter(accountThrottleKey(user.Email), time.Now())
+		if err != nil {
+			return err
+		}
+		if wait > 0 {
+			return ErrTooManyAttempts
+		}
+
+		codeErr = checkSecondFactor(tx, user, req.secondFactor)
+		if errors.Is(codeErr, ErrInvalidCode) {
+			challenge.Attempts++
+			if err := tx.Model(challenge).Update("attempts", challenge.Attempts).Error; err != nil {
+				return err
+			}
+			if challenge.Attempts >= maxChallengeAttempts {
+				return markTokenUsed(tx, challenge)
+			}
+			return nil
+		}
+		if codeErr != nil {
+			return codeErr
+		}
+
+		return markTokenUsed(tx, challenge)
+	})
+	if errors.Is(codeErr, ErrInvalidCode) {
+		// Wrong codes count like wrong passwords, so an attacker who knows
+		// the password cannot guess codes through fresh challenges.
+		recordAuthEvent(r, authEvent{Event: EventTwoFactorFailed, UserID: user.ID, Email: user.Email})
+		if locked, lockErr := accountThrottle.recordFailure(accountThrottleKey(user.Email), time.Now()); lockErr != nil {
+			log.Printf("Failed to record two-factor failure: %v", lockErr)
+		} else if locked {
+			recordAuthEvent(r, authEvent{Event: EventAccountLocked, UserID: user.ID, Email: user.Email,
+				Detail: fmt.Sprintf("locked for %s", accountThrottle.LockFor)})
+		}
+	}
+	if err == nil {
+		err = codeErr
+	}
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	if err := clearThrottle(accountThrottleKey(user.Email)); err != nil {
+		log.Printf("Failed to reset login throttle of user %d: %v", user.ID, err)
+	}
+	if err := utils.CreateSession(w, r, user.ID, true); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	recordAuthEvent(r, authEvent{Event: EventLoginSucceeded, UserID: user.ID, Email: user.Email, Detail: "two-factor"})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Login successful"}`))
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// UserRole grants a role from the rbac package to a user.
+type UserRole struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"granted_at"`
+	UserID    uint      `json:"-" gorm:"not null;uniqueIndex:idx_user_role"`
+	Role      string    `json:"role" gorm:"not null;uniqueIndex:idx_user_role"`
+	GrantedBy uint      `json:"granted_by"` // 0 for roles granted by migrations or bootstrap
+}
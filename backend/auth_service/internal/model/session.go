@@ -8,6 +8,14 @@ import (
 type Session struct {
 	gorm.Model
 	Token     string    `gorm:"uniqueIndex;not null"` // Unique session token
-	ExpiresAt time.Time `gorm:"not null"`             // Expiration time
-	UserID    uint      `gorm:"not null"`             // User reference
+	ExpiresAt time.Time `gorm:"not null"`             // Expiration time, moved forward while the session is used
+	UserID    uint      `gorm:"not null;index"`       // User reference
+	// MaxExpiresAt caps how far ExpiresAt can slide, so a session that is
+	// used every day still ends eventually.
+	MaxExpiresAt time.Time `gorm:"not null;default:now()"`
+	LastSeenAt   time.Time `gorm:"not null;default:now()"`
+	UserAgent    string
+	IP           string
+	// TwoFactor is set when the login passed a second factor.
+	TwoFactor bool `gorm:"not null;default:false"`
 }
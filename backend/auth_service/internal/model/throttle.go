@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one key, either an account
+// ("account:<email>") or a client address ("ip:<address>"). Keys for
+// unknown emails are tracked the same way, so throttling does not reveal
+// which accounts exist.
+type LoginThrottle struct {
+	Key           string `gorm:"primaryKey"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// AuthEvent is an entry of the auth audit log.
+type AuthEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	Event     string    `json:"event" gorm:"not null;index"`
+	UserID    *uint     `json:"user_id" gorm:"index"` // Unset for unknown emails and address locks
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty" gorm:"index"`
+	ActorID   *uint     `json:"actor_id,omitempty"` // The admin behind an admin action
+	Detail    string    `json:"detail,omitempty"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	// TokenLoginChallenge links the two steps of a login with two-factor
+	// authentication.
+	TokenLoginChallenge = "login_challenge"
+)
+
+// UserToken is a single-use token, sent by email or handed out between the
+// steps of a login. Only the SHA-256 hash of
+// the token is stored, so a leaked table cannot be used to take over
+// accounts.
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	CreatedAt time.Time  `gorm:"index"`
+	UserID    uint       `gorm:"not null;index"`
+	Purpose   string     `gorm:"not null;index"`
+	TokenHash string     `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // Set once the token has been redeemed
+	Attempts  int        `gorm:"not null;default:0"` // Failed attempts, for tokens that guard a code
+}
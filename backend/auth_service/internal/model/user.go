@@ -1,11 +1,42 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
 
 type User struct {
 	gorm.Model
 	Username string `gorm:"unique;not null"`
 	Email    string `gorm:"unique;	not null"`
 	Password string `gorm:"not null"`
-	IsAdmin  bool   `json:"is_admin" gorm:"default:false"`
+	// Roles grant admin permissions; see the rbac package.
+	Roles []UserRole `json:"roles,omitempty"`
+	// EmailVerifiedAt is set once the user follows the link sent to their
+	// address. Unverified accounts cannot act as admins or sign up for
+	// events.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// TOTPSecret is set during enrolment and only takes effect once
+	// TOTPEnabledAt is set by a confirmed code.
+	TOTPSecret    string     `json:"-"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	// TOTPLastCounter is the time step of the last accepted code, so that a
+	// code cannot be replayed.
+	TOTPLastCounter int64 `json:"-"`
+}
+
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// NormalizeEmail is the form emails are stored and looked up in. Accounts
+// created before emails were normalized may still hold mixed case, so
+// lookups compare against lower(email).
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
@@ -0,0 +1,86 @@
+// Package rbac defines the roles users can hold and the permissions each
+// role grants. Other services only see permissions, so roles can be
+// reshaped here without changing them.
+package rbac
+
+import "sort"
+
+const (
+	RoleUser             = "user"
+	RoleEventEditor      = "event_editor"
+	RoleAttractionEditor = "attraction_editor"
+	RoleModerator        = "moderator"
+	RoleSuperAdmin       = "super_admin"
+)
+
+const (
+	PermEventsView         = "events.view"
+	PermEventsEdit         = "events.edit"
+	PermEventsPublish      = "events.publish"
+	PermEventsAttendees    = "events.attendees"
+	PermAttractionsView    = "attractions.view"
+	PermAttractionsEdit    = "attractions.edit"
+	PermAttractionsPublish = "attractions.publish"
+	PermAuditRead          = "audit.read"
+	PermContentEditAny     = "content.edit_any" // Overrides the owner-only edit policy
+	PermRolesManage        = "roles.manage"
+	PermUsersUnlock        = "users.unlock"
+	PermAuthAuditRead      = "auth_audit.read"
+	PermMediaUpload        = "media.upload"
+	PermMediaManage        = "media.manage" // Storage sweeps and the usage report
+)
+
+// rolePermissions lists what each role may do. Every account implicitly
+// holds RoleUser, which grants nothing beyond the user-facing endpoints.
+var rolePermissions = map[string][]string{
+	RoleUser: {},
+	RoleEventEditor: {
+		PermEventsView, PermEventsEdit, PermEventsPublish, PermEventsAttendees, PermMediaUpload,
+	},
+	RoleAttractionEditor: {
+		PermAttractionsView, PermAttractionsEdit, PermAttractionsPublish, PermMediaUpload,
+	},
+	RoleModerator: {
+		PermEventsView, PermEventsPublish, PermAttractionsView, PermAttractionsPublish,
+		PermAuditRead, PermContentEditAny, PermUsersUnlock,
+	},
+	RoleSuperAdmin: {
+		PermEventsView, PermEventsEdit, PermEventsPublish, PermEventsAttendees,
+		PermAttractionsView, PermAttractionsEdit, PermAttractionsPublish,
+		PermAuditRead, PermContentEditAny, PermRolesManage, PermUsersUnlock, PermAuthAuditRead,
+		PermMediaUpload, PermMediaManage,
+	},
+}
+
+// Valid reports whether role is known. RoleUser is not granted explicitly.
+func Valid(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok && role != RoleUser
+}
+
+// Roles returns the grantable roles with their permissions.
+func Roles() map[string][]string {
+	roles := make(map[string][]string, len(rolePermissions)-1)
+	for role, permissions := range rolePermissions {
+		if role != RoleUser {
+			roles[role] = append([]string(nil), permissions...)
+		}
+	}
+	return roles
+}
+
+// Permissions returns the sorted union of the permissions of roles.
+func Permissions(roles []string) []string {
+	set := make(map[string]bool)
+	for _, role := range roles {
+		for _, permission := range rolePermissions[role] {
+			set[permission] = true
+		}
+	}
+	permissions := make([]string, 0, len(set))
+	for permission := range set {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
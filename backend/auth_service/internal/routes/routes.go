@@ -2,6 +2,7 @@ package routes
 
 import (
 	"authorization_service/internal/controllers"
+	"authorization_service/internal/rbac"
 	"authorization_service/middleware"
 	"github.com/gorilla/mux"
 )
@@ -12,14 +13,38 @@ func SetupRoutes() *mux.Router {
 	// Public routes
 	r.HandleFunc("/register", controllers.Register).Methods("POST")
 	r.HandleFunc("/login", controllers.Login).Methods("POST")
+	r.HandleFunc("/login/2fa", controllers.LoginTwoFactor).Methods("POST")
 	r.HandleFunc("/validate-session", controllers.ValidateSession).Methods("GET") // Add this line
 	r.HandleFunc("/validate-admin", controllers.ValidateAdmin).Methods("GET")     // Add this line
+	r.HandleFunc("/verify-email", controllers.VerifyEmail).Methods("POST")
+	r.HandleFunc("/password/forgot", controllers.ForgotPassword).Methods("POST")
+	r.HandleFunc("/password/reset", controllers.ResetPassword).Methods("POST")
 
 	// Protected routes
 	protected := r.PathPrefix("/").Subrouter()
 	protected.Use(middleware.AuthMiddleware)
 	protected.HandleFunc("/profile", controllers.GetProfile).Methods("GET")
 	protected.HandleFunc("/profile", controllers.Logout).Methods("POST")
+	protected.HandleFunc("/verify-email/resend", controllers.ResendVerification).Methods("POST")
+	protected.HandleFunc("/password/change", controllers.ChangePassword).Methods("POST")
+	protected.HandleFunc("/sessions", controllers.ListSessions).Methods("GET")
+	protected.HandleFunc("/sessions/revoke-all", controllers.RevokeAllSessions).Methods("POST")
+	protected.HandleFunc("/sessions/{id:[0-9]+}", controllers.RevokeSession).Methods("DELETE")
+	protected.HandleFunc("/2fa", controllers.TwoFactorStatus).Methods("GET")
+	protected.HandleFunc("/2fa/setup", controllers.SetupTwoFactor).Methods("POST")
+	protected.HandleFunc("/2fa/confirm", controllers.ConfirmTwoFactor).Methods("POST")
+	protected.HandleFunc("/2fa/disable", controllers.DisableTwoFactor).Methods("POST")
+	protected.HandleFunc("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes).Methods("POST")
+
+	// Role management for super-admins
+	protected.HandleFunc("/admin/roles", middleware.RequirePermission(rbac.PermRolesManage, controllers.ListRoles)).Methods("GET")
+	protected.HandleFunc("/admin/users/{id:[0-9]+}/roles", middleware.RequirePermission(rbac.PermRolesManage, controllers.GetUserRoles)).Methods("GET")
+	protected.HandleFunc("/admin/users/{id:[0-9]+}/roles", middleware.RequirePermission(rbac.PermRolesManage, controllers.GrantRole)).Methods("POST")
+	protected.HandleFunc("/admin/users/{id:[0-9]+}/roles/{role}", middleware.RequirePermission(rbac.PermRolesManage, controllers.RevokeRole)).Methods("DELETE")
+
+	// Account security
+	protected.HandleFunc("/admin/users/{id:[0-9]+}/unlock", middleware.RequirePermission(rbac.PermUsersUnlock, controllers.UnlockUser)).Methods("POST")
+	protected.HandleFunc("/admin/auth-events", middleware.RequirePermission(rbac.PermAuthAuditRead, controllers.ListAuthEvents)).Methods("GET")
 
 	return r
 }
@@ -1,22 +1,68 @@
 package middleware
 
 import (
+	"authorization_service/internal/model"
+	"authorization_service/internal/rbac"
+	"authorization_service/utils/db"
 	"authorization_service/utils/session"
+	"authorization_service/utils/totp"
 	"context"
 	"net/http"
 )
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, authenticated := utils.GetSessionUserID(r)
+		session, authenticated := utils.GetSession(r)
 		if !authenticated {
 			http.Error(w, "Unauthorized user", http.StatusUnauthorized)
 			return
 		}
 
 		ctx := r.Context()
-		ctx = context.WithValue(ctx, "user_id", userID)
+		ctx = context.WithValue(ctx, "user_id", session.UserID)
+		ctx = context.WithValue(ctx, "session_id", session.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 
 	})
 }
+
+// RequirePermission guards a handler behind AuthMiddleware that only users
+// holding permission may call.
+func RequirePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value("user_id").(uint)
+
+		var user model.User
+		if err := db.DB.Preload("Roles").First(&user, userID).Error; err != nil {
+			http.Error(w, "Unauthorized user", http.StatusUnauthorized)
+			return
+		}
+		roles := make([]string, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			roles = append(roles, role.Role)
+		}
+		for _, granted := range rbac.Permissions(roles) {
+			if granted != permission {
+				continue
+			}
+			if totp.RequiredForAdmins() && !twoFactorSession(r) {
+				http.Error(w, "Forbidden - admin access requires two-factor authentication", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+			return
+		}
+		http.Error(w, "Forbidden - missing permission "+permission, http.StatusForbidden)
+	}
+}
+
+// twoFactorSession reports whether the request's session passed a second
+// factor at login.
+func twoFactorSession(r *http.Request) bool {
+	sessionID, _ := r.Context().Value("session_id").(uint)
+	var session model.Session
+	if err := db.DB.Select("id", "two_factor").First(&session, sessionID).Error; err != nil {
+		return false
+	}
+	return session.TwoFactor
+}
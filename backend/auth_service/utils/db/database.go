@@ -2,9 +2,12 @@ package db
 
 import (
 	"authorization_service/internal/model"
+	"authorization_service/internal/rbac"
+	"errors"
 	"fmt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log"
 	"math"
 	"os"
@@ -65,11 +68,94 @@ func ConnectDB() {
 		log.Fatal("Database connection is nil after initialization!")
 	}
 
+	// Accounts created before email verification existed are treated as
+	// verified, so that their owners are not locked out.
+	backfillVerified := !DB.Migrator().HasColumn(&model.User{}, "email_verified_at")
+	// Roles replace the is_admin and is_super_admin flags; existing admins
+	// keep what they could do.
+	backfillRoles := !DB.Migrator().HasTable(&model.UserRole{}) &&
+		DB.Migrator().HasColumn("users", "is_admin")
+	// Sessions from before sliding expiry keep the expiry they were given.
+	backfillSessions := !DB.Migrator().HasColumn(&model.Session{}, "max_expires_at")
+
 	// Run AutoMigrate for your models
-	err = DB.AutoMigrate(&model.User{}, &model.Session{}) // Add your models here
+	err = DB.AutoMigrate(&model.User{}, &model.Session{}, &model.UserToken{}, &model.UserRole{}, &model.RecoveryCode{}, &model.LoginThrottle{}, &model.AuthEvent{}) // Add your models here
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// Emails are looked up case-insensitively.
+	if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_users_lower_email ON users (lower(email))").Error; err != nil {
+		log.Fatalf("Failed to index user emails: %v", err)
+	}
+
+	if backfillVerified {
+		if err := DB.Model(&model.User{}).Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).Error; err != nil {
+			log.Fatalf("Failed to mark existing users as verified: %v", err)
+		}
+	}
+	if backfillSessions {
+		if err := DB.Model(&model.Session{}).Where("1 = 1").UpdateColumns(map[string]interface{}{
+			"max_expires_at": gorm.Expr("expires_at"),
+			"last_seen_at":   gorm.Expr("updated_at"),
+		}).Error; err != nil {
+			log.Fatalf("Failed to migrate existing sessions: %v", err)
+		}
+	}
+
+	if backfillRoles {
+		if err := migrateAdminFlags(); err != nil {
+			log.Fatalf("Failed to migrate admin flags to roles: %v", err)
+		}
+	}
+	if err := bootstrapSuperAdmin(); err != nil {
+		log.Fatalf("Failed to bootstrap super-admin: %v", err)
+	}
+
 	log.Println("Connected to PostgreSQL database successfully!")
 }
+
+// migrateAdminFlags grants admins both editor roles, and super-admins the
+// super-admin role.
+func migrateAdminFlags() error {
+	grants := []struct {
+		role, condition string
+	}{
+		{rbac.RoleEventEditor, "is_admin"},
+		{rbac.RoleAttractionEditor, "is_admin"},
+	}
+	if DB.Migrator().HasColumn("users", "is_super_admin") {
+		grants = append(grants, struct{ role, condition string }{rbac.RoleSuperAdmin, "is_admin AND is_super_admin"})
+	}
+
+	for _, grant := range grants {
+		if err := DB.Exec(`INSERT INTO user_roles (created_at, user_id, role, granted_by)
+			SELECT now(), id, ?, 0 FROM users WHERE deleted_at IS NULL AND `+grant.condition+`
+			ON CONFLICT DO NOTHING`, grant.role).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bootstrapSuperAdmin makes the user with SUPER_ADMIN_EMAIL a super-admin,
+// so a fresh installation has someone who can grant roles.
+func bootstrapSuperAdmin() error {
+	email := model.NormalizeEmail(os.Getenv("SUPER_ADMIN_EMAIL"))
+	if email == "" {
+		return nil
+	}
+
+	var user model.User
+	err := DB.Where("lower(email) = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("SUPER_ADMIN_EMAIL %s has no account yet", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.UserRole{UserID: user.ID, Role: rbac.RoleSuperAdmin}).Error
+}
@@ -0,0 +1,75 @@
+// Package mail sends the account emails of the auth service. The backend is
+// chosen with MAIL_BACKEND: "smtp" delivers through an SMTP server, and
+// "log" (the default) only writes messages to the log, which is enough for
+// local development.
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers a message or reports why it could not.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default is the mailer used by Send. It is set by Setup.
+var Default Mailer = LogMailer{}
+
+// Setup selects the mailer from the environment.
+func Setup() error {
+	switch backend := os.Getenv("MAIL_BACKEND"); backend {
+	case "", "log":
+		Default = LogMailer{}
+	case "smtp":
+		mailer, err := NewSMTPMailer()
+		if err != nil {
+			return err
+		}
+		Default = mailer
+	default:
+		return fmt.Errorf("unknown MAIL_BACKEND %q", backend)
+	}
+	return nil
+}
+
+func Send(msg Message) error {
+	return Default.Send(msg)
+}
+
+// LogMailer writes messages to the log instead of sending them.
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// MemoryMailer keeps sent messages, so tests can read the links they hold.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
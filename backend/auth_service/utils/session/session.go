@@ -5,34 +5,120 @@ import (
 	"authorization_service/utils/db"
 	"crypto/rand"
 	"encoding/hex"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
+const (
+	// IdleTimeout ends a session that has not been used for this long.
+	IdleTimeout = 24 * time.Hour
+	// MaxLifetime ends a session this long after login, however often it
+	// is used.
+	MaxLifetime = 30 * 24 * time.Hour
+	// touchInterval limits how often using a session writes to the
+	// database.
+	touchInterval = time.Minute
+)
+
 func generateSessionToken() string {
 	bytes := make([]byte, 32)
 	_, _ = rand.Read(bytes)
 	return hex.EncodeToString(bytes)
 }
 
-func CreateSession(w http.ResponseWriter, r *http.Request, userID uint) error {
+// trustedProxies are the TRUSTED_PROXIES entries, comma separated: IP
+// addresses, CIDR ranges or host names such as gateway-service. Only these
+// hosts may report the client address in X-Forwarded-For.
+var trustedProxies = strings.Split(os.Getenv("TRUSTED_PROXIES"), ",")
+
+// isTrustedProxy reports whether a connection from host came through one of
+// the trusted proxies. Host names are resolved on every call, since
+// container addresses change when the proxy restarts.
+func isTrustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range trustedProxies {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if proxyIP := net.ParseIP(entry); proxyIP != nil {
+			if proxyIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		addrs, err := net.LookupIP(entry)
+		if err != nil {
+			log.Printf("Failed to resolve trusted proxy %s: %v", entry, err)
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client. A request from a trusted
+// proxy carries it in the last X-Forwarded-For entry, the one the proxy
+// added; earlier entries come from the client. Anyone else connecting
+// directly is identified by the connection address, whatever headers they
+// send.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" && isTrustedProxy(host) {
+		entries := strings.Split(forwarded, ",")
+		return strings.TrimSpace(entries[len(entries)-1])
+	}
+	return host
+}
+
+// CreateSession logs the user in. twoFactor records whether the login
+// passed a second factor.
+func CreateSession(w http.ResponseWriter, r *http.Request, userID uint, twoFactor bool) error {
 	sessionToken := generateSessionToken()
-	expiration := time.Now().Add(24 * time.Hour)
+	now := time.Now()
+	maxExpiration := now.Add(MaxLifetime)
 
 	// Save session to database
 	session := model.Session{
-		UserID:    userID,
-		Token:     sessionToken,
-		ExpiresAt: expiration,
+		UserID:       userID,
+		Token:        sessionToken,
+		ExpiresAt:    now.Add(IdleTimeout),
+		MaxExpiresAt: maxExpiration,
+		LastSeenAt:   now,
+		UserAgent:    r.UserAgent(),
+		IP:           ClientIP(r),
+		TwoFactor:    twoFactor,
 	}
 	if err := db.DB.Create(&session).Error; err != nil {
 		return err
 	}
 
+	// The cookie lives as long as the session could; the idle timeout is
+	// enforced here.
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
 		Value:    sessionToken,
-		Expires:  expiration,
+		Expires:  maxExpiration,
 		HttpOnly: true,
 		Path:     "/",
 		SameSite: http.SameSiteNoneMode,
@@ -40,21 +126,63 @@ func CreateSession(w http.ResponseWriter, r *http.Request, userID uint) error {
 	return nil
 }
 
-func GetSessionUserID(r *http.Request) (uint, bool) {
+// GetSession returns the unexpired session of the request's cookie and
+// slides its expiry forward.
+func GetSession(r *http.Request) (*model.Session, bool) {
 	cookie, err := r.Cookie("session_token")
 	if err != nil {
-		return 0, false
+		return nil, false
 	}
 
+	now := time.Now()
 	var session model.Session
-	if err := db.DB.Where("token = ? AND expires_at > ?",
-		cookie.Value, time.Now()).First(&session).Error; err != nil {
-		return 0, false
+	if err := db.DB.Where("token = ? AND expires_at > ? AND max_expires_at > ?",
+		cookie.Value, now, now).First(&session).Error; err != nil {
+		return nil, false
+	}
+
+	if now.Sub(session.LastSeenAt) >= touchInterval {
+		expiresAt := now.Add(IdleTimeout)
+		if expiresAt.After(session.MaxExpiresAt) {
+			expiresAt = session.MaxExpiresAt
+		}
+		session.ExpiresAt = expiresAt
+		session.LastSeenAt = now
+		if err := db.DB.Model(&session).UpdateColumns(map[string]interface{}{
+			"expires_at":   expiresAt,
+			"last_seen_at": now,
+		}).Error; err != nil {
+			log.Printf("Failed to extend session %d: %v", session.ID, err)
+		}
 	}
+	return &session, true
+}
 
+func GetSessionUserID(r *http.Request) (uint, bool) {
+	session, ok := GetSession(r)
+	if !ok {
+		return 0, false
+	}
 	return session.UserID, true
 }
 
+// RevokeUserSessions ends every session of the user except keepID, which
+// may be 0 to end them all.
+func RevokeUserSessions(userID, keepID uint) error {
+	return db.DB.Where("user_id = ? AND id <> ?", userID, keepID).Delete(&model.Session{}).Error
+}
+
+// ClearCookie tells the browser to drop the session cookie.
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    "",
+		Expires:  time.Now().Add(-1 * time.Hour),
+		HttpOnly: true,
+		Path:     "/",
+	})
+}
+
 func DestroySession(w http.ResponseWriter, r *http.Request) error {
 	cookie, err := r.Cookie("session_token")
 	if err != nil {
@@ -67,12 +195,27 @@ func DestroySession(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	// Clear the cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour),
-		HttpOnly: true,
-		Path:     "/",
-	})
+	ClearCookie(w)
 	return nil
 }
+
+// StartCleanup removes expired and revoked sessions once an hour, so the
+// table does not keep growing.
+func StartCleanup() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			now := time.Now()
+			result := db.DB.Unscoped().
+				Where("expires_at < ? OR max_expires_at < ? OR deleted_at IS NOT NULL", now, now).
+				Delete(&model.Session{})
+			if result.Error != nil {
+				log.Printf("Session cleanup failed: %v", result.Error)
+			} else if result.RowsAffected > 0 {
+				log.Printf("Removed %d ended sessions", result.RowsAffected)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
+	"diplomaPorject/backend/events_service/internal/payments"
 	routes1 "diplomaPorject/backend/events_service/internal/routes"
+	"diplomaPorject/backend/events_service/internal/scheduler"
 	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/events_service/utils/tickets"
 	"log"
 	"net/http"
 )
 
 func main() {
+	if err := tickets.Setup(); err != nil {
+		log.Fatal(err)
+	}
 	db.ConnectDB()
+	if err := payments.Setup(); err != nil {
+		log.Fatal(err)
+	}
+	scheduler.Start(context.Background())
 	router := routes1.SetupRoutes()
 	log.Println("Events service running on port 8083...")
 	log.Fatal(http.ListenAndServe(":8083", router))
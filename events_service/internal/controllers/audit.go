@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// ListAuditEntries returns the audit log, newest first. It can be filtered
+// by admin_id, entity_id, action and a from/to date range.
+func ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := db.DB.Model(&models.AuditEntry{})
+
+	for _, name := range []string{"admin_id", "entity_id"} {
+		value := q.Get(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeParamError(w, &ParamError{Parameter: name, Message: "must be a positive integer"})
+			return
+		}
+		query = query.Where(name+" = ?", id)
+	}
+	if action := q.Get("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	from, perr := parseDateParam("from", q.Get("from"))
+	if perr != nil {
+		writeParamError(w, perr)
+		return
+	}
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	to, perr := parseDateParam("to", q.Get("to"))
+	if perr != nil {
+		writeParamError(w, perr)
+		return
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+
+	page, perr := parsePositiveInt("page", q.Get("page"), 1)
+	if perr != nil {
+		writeParamError(w, perr)
+		return
+	}
+	pageSize, perr := parsePositiveInt("page_size", q.Get("page_size"), defaultPageSize)
+	if perr != nil {
+		writeParamError(w, perr)
+		return
+	}
+	if pageSize > maxPageSize {
+		writeParamError(w, &ParamError{Parameter: "page_size", Message: fmt.Sprintf("must not exceed %d", maxPageSize)})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+	entries := []models.AuditEntry{}
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&entries).Error; err != nil {
+		http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Entries    []models.AuditEntry `json:"entries"`
+		Total      int64               `json:"total"`
+		Page       int                 `json:"page"`
+		PageSize   int                 `json:"page_size"`
+		TotalPages int                 `json:"total_pages"`
+	}{
+		Entries:    entries,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(pageSize))),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/audit"
+	"diplomaPorject/backend/shared/bulk"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxImportRows  = 5000
+	maxImportBytes = 10 << 20
+)
+
+// eventColumns are the columns of event imports and exports. Rows with an id
+// update that event; rows without one create a new event.
+var eventColumns = []string{
+	"id", "title", "description", "start_date", "end_date", "location", "capacity",
+	"category", "image_url", "recurrence_rule", "latitude", "longitude",
+}
+
+// ImportEvents creates and updates events from a CSV or JSON document. Every
+// row is validated like CreateEvent first, and an update may not lower an
+// event's capacity below its taken seats; if any row fails, nothing is
+// written and the report lists the failures. With dry_run=true the report is
+// returned without writing even when all rows are valid.
+func ImportEvents(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - Admin ID missing", http.StatusUnauthorized)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	body, format, err := bulk.ReadUpload(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := bulk.ReadRows(body, format, eventColumns, maxImportRows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := bulk.NewReport(dryRun, len(rows))
+	events := make([]*models.Event, len(rows))
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		event, err := eventFromFields(row.Get)
+		if err == nil {
+			ids[i], err = row.ID()
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, bulk.RowError{Row: row.Number, Message: err.Error()})
+			continue
+		}
+		events[i] = event
+	}
+
+	existing, err := existingEvents(ids)
+	if err != nil {
+		http.Error(w, "Failed to check existing events", http.StatusInternalServerError)
+		return
+	}
+	for i, id := range ids {
+		if id == 0 || events[i] == nil {
+			continue
+		}
+		current, ok := existing[id]
+		switch {
+		case !ok:
+			report.Errors = append(report.Errors, bulk.RowError{Row: rows[i].Number, Message: fmt.Sprintf("event %d not found", id)})
+		case !access.MayEdit(r, current.AdminID):
+			report.Errors = append(report.Errors, bulk.RowError{Row: rows[i].Number, Message: fmt.Sprintf("event %d: %s", id, access.ErrNotOwner)})
+		case events[i].Capacity < current.CurrentCount:
+			report.Errors = append(report.Errors, bulk.RowError{Row: rows[i].Number,
+				Message: fmt.Sprintf("event %d: %s (%d taken)", id, ErrCapacityBelowCount, current.CurrentCount)})
+		}
+	}
+
+	for i, id := range ids {
+		if events[i] == nil {
+			continue
+		}
+		if id == 0 {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+	}
+	if len(report.Errors) > 0 {
+		bulk.WriteReport(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if dryRun {
+		bulk.WriteReport(w, http.StatusOK, report)
+		return
+	}
+
+	var rowErr *bulk.RowError
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		for i, event := range events {
+			if ids[i] == 0 {
+				event.AdminID = adminID
+				if err := tx.Create(event).Error; err != nil {
+					return fmt.Errorf("row %d: %w", rows[i].Number, err)
+				}
+				if err := models.EventAudit.Record(tx, adminID, audit.ActionCreate, event.ID, nil, event); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var current models.Event
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, ids[i]).Error; err != nil {
+				return fmt.Errorf("row %d: %w", rows[i].Number, err)
+			}
+			before, err := audit.Snapshot(&current)
+			if err != nil {
+				return err
+			}
+			if err := saveEventUpdate(tx, &current, event); errors.Is(err, ErrCapacityBelowCount) {
+				// Registrations came in after the rows were checked.
+				rowErr = &bulk.RowError{Row: rows[i].Number, Message: fmt.Sprintf("event %d: %s", current.ID, err)}
+				return err
+			} else if err != nil {
+				return fmt.Errorf("row %d: %w", rows[i].Number, err)
+			}
+			if err := models.EventAudit.Record(tx, adminID, audit.ActionUpdate, current.ID, before, &current); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if rowErr != nil {
+		report.Created, report.Updated = 0, 0
+		report.Errors = append(report.Errors, *rowErr)
+		bulk.WriteReport(w, http.StatusConflict, report)
+		return
+	}
+	if err != nil {
+		log.Printf("Event import failed: %v", err)
+		http.Error(w, "Failed to import events", http.StatusInternalServerError)
+		return
+	}
+
+	bulk.WriteReport(w, http.StatusOK, report)
+}
+
+// eventRecord returns the export record of an event, in eventColumns order.
+func eventRecord(event *models.Event) []interface{} {
+	return []interface{}{
+		event.ID, event.Title, event.Description,
+		event.StartDate.Format(time.RFC3339), event.EndDate.Format(time.RFC3339),
+		event.Location, event.Capacity, event.Category, event.ImageURL,
+		event.RecurrenceRule, event.Latitude, event.Longitude,
+	}
+}
+
+// existingEvents maps the IDs of existing events to their owner and taken
+// seats, the fields an import row is checked against.
+func existingEvents(ids []uint) (map[uint]models.Event, error) {
+	var wanted []uint
+	for _, id := range ids {
+		if id != 0 {
+			wanted = append(wanted, id)
+		}
+	}
+	existing := make(map[uint]models.Event, len(wanted))
+	if len(wanted) == 0 {
+		return existing, nil
+	}
+
+	var found []models.Event
+	if err := db.DB.Select("id", "admin_id", "current_count").Where("id IN ?", wanted).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for _, event := range found {
+		existing[event.ID] = event
+	}
+	return existing, nil
+}
+
+// ExportEvents writes every event in the format ImportEvents reads.
+func ExportEvents(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = bulk.FormatCSV
+	}
+	if format != bulk.FormatCSV && format != bulk.FormatJSON {
+		http.Error(w, bulk.ErrUnknownFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var events []models.Event
+	query := db.DB.Order("id ASC")
+	if category := r.URL.Query().Get("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if err := query.Find(&events).Error; err != nil {
+		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
+		return
+	}
+
+	records := make([][]interface{}, 0, len(events))
+	for _, event := range events {
+		records = append(records, eventRecord(&event))
+	}
+
+	if err := bulk.Write(w, format, "events", eventColumns, records); err != nil {
+		log.Printf("Event export failed: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/events_service/utils/ical"
+	"diplomaPorject/backend/events_service/utils/recurrence"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+)
+
+// calendarUID is stable for the lifetime of an event so that subscribed
+// calendars update the existing entry instead of adding a new one.
+func calendarUID(event *models.Event) string {
+	return fmt.Sprintf("event-%d@travelkz", event.ID)
+}
+
+func toCalendarEvent(event *models.Event) ical.Event {
+	return ical.Event{
+		UID:          calendarUID(event),
+		Sequence:     event.Sequence,
+		Created:      event.CreatedAt,
+		LastModified: event.UpdatedAt,
+		Start:        event.StartDate,
+		End:          event.EndDate,
+		Summary:      event.Title,
+		Description:  event.Description,
+		Location:     event.Location,
+		Categories:   event.Category,
+	}
+}
+
+// toCalendarEvents returns the VEVENTs for an event: the event itself and,
+// for a recurring series, one VEVENT per modified occurrence in occurrence
+// order. Cancelled occurrences become EXDATEs on the series. A series is
+// written in the zone its occurrences are computed in, so that calendars
+// expand the rule to the same local times.
+func toCalendarEvents(event *models.Event, overrides map[int64]models.EventOccurrenceOverride) []ical.Event {
+	master := toCalendarEvent(event)
+	if event.RecurrenceRule == "" {
+		return []ical.Event{master}
+	}
+
+	master.RRule = event.RecurrenceRule
+	master.TimeZone = recurrence.Location
+
+	starts := make([]int64, 0, len(overrides))
+	for start := range overrides {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	var modified []ical.Event
+	for _, start := range starts {
+		override := overrides[start]
+		if override.Cancelled {
+			master.ExDates = append(master.ExDates, override.OccurrenceStart)
+			continue
+		}
+
+		occurrence := *event
+		duration := event.EndDate.Sub(event.StartDate)
+		occurrence.StartDate = override.OccurrenceStart
+		occurrence.EndDate = override.OccurrenceStart.Add(duration)
+		applyOverride(&occurrence, override)
+
+		entry := toCalendarEvent(&occurrence)
+		recurrenceID := override.OccurrenceStart
+		entry.RecurrenceID = &recurrenceID
+		entry.LastModified = override.UpdatedAt
+		entry.TimeZone = recurrence.Location
+		modified = append(modified, entry)
+	}
+	return append([]ical.Event{master}, modified...)
+}
+
+func writeCalendar(w http.ResponseWriter, calendar *ical.Calendar, filename string) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	w.Write(calendar.Bytes())
+}
+
+func GetEventICS(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var event models.Event
+	if err := db.DB.Where("is_published = ?", true).First(&event, id).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+
+	overrides, err := loadOverrides([]uint{event.ID})
+	if err != nil {
+		http.Error(w, "Failed to fetch event", http.StatusInternalServerError)
+		return
+	}
+
+	calendar := &ical.Calendar{
+		Name:   event.Title,
+		Events: toCalendarEvents(&event, overrides[event.ID]),
+	}
+	writeCalendar(w, calendar, fmt.Sprintf("event-%d.ics", event.ID))
+}
+
+func GetEventsCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	var events []models.Event
+	query := db.DB.Where("is_published = ?", true)
+
+	name := "TravelKZ Events"
+	if category := r.URL.Query().Get("category"); category != "" {
+		query = query.Where("category = ?", category)
+		name = fmt.Sprintf("TravelKZ Events - %s", category)
+	}
+
+	if err := query.Order("start_date ASC").Find(&events).Error; err != nil {
+		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]uint, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	overrides, err := loadOverrides(ids)
+	if err != nil {
+		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
+		return
+	}
+
+	calendar := &ical.Calendar{Name: name}
+	for i := range events {
+		calendar.Events = append(calendar.Events, toCalendarEvents(&events[i], overrides[events[i].ID])...)
+	}
+	writeCalendar(w, calendar, "events.ics")
+}
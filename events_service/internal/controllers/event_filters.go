@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+
+	// defaultExpansionHorizon is how far ahead recurring events are expanded
+	// when the caller does not pass "to".
+	defaultExpansionHorizon = 90 * 24 * time.Hour
+)
+
+// sortColumns maps the public sort names to columns.
+var sortColumns = map[string]string{
+	"start_date": "start_date",
+	"created_at": "created_at",
+	"popularity": "current_count",
+}
+
+type eventListParams struct {
+	Category    string
+	Location    string
+	Query       string
+	From        *time.Time
+	To          *time.Time
+	IncludePast bool
+	Sort        string
+	Desc        bool
+	Page        int
+	PageSize    int
+}
+
+// ParamError reports which query parameter was rejected.
+type ParamError struct {
+	Parameter string `json:"parameter"`
+	Message   string `json:"message"`
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Parameter, e.Message)
+}
+
+func writeParamError(w http.ResponseWriter, err *ParamError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		*ParamError
+	}{
+		Error:      "invalid_parameter",
+		ParamError: err,
+	})
+}
+
+// parseDateParam accepts RFC3339 timestamps and plain YYYY-MM-DD dates.
+func parseDateParam(name, value string) (*time.Time, *ParamError) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return &t, nil
+	}
+	return nil, &ParamError{Parameter: name, Message: "expected RFC3339 timestamp or YYYY-MM-DD date"}
+}
+
+func parsePositiveInt(name, value string, fallback int) (int, *ParamError) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, &ParamError{Parameter: name, Message: "must be a positive integer"}
+	}
+	return n, nil
+}
+
+func parseEventListParams(r *http.Request) (*eventListParams, *ParamError) {
+	q := r.URL.Query()
+	params := &eventListParams{
+		Category: strings.TrimSpace(q.Get("category")),
+		Location: strings.TrimSpace(q.Get("location")),
+		Query:    strings.TrimSpace(q.Get("q")),
+		Sort:     "start_date",
+	}
+
+	var perr *ParamError
+	if params.From, perr = parseDateParam("from", q.Get("from")); perr != nil {
+		return nil, perr
+	}
+	if params.To, perr = parseDateParam("to", q.Get("to")); perr != nil {
+		return nil, perr
+	}
+	if params.From != nil && params.To != nil && !params.To.After(*params.From) {
+		return nil, &ParamError{Parameter: "to", Message: "must be after from"}
+	}
+
+	if value := q.Get("include_past"); value != "" {
+		includePast, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, &ParamError{Parameter: "include_past", Message: "must be true or false"}
+		}
+		params.IncludePast = includePast
+	}
+
+	if value := q.Get("sort"); value != "" {
+		if _, ok := sortColumns[value]; !ok {
+			return nil, &ParamError{Parameter: "sort", Message: "must be one of start_date, created_at, popularity"}
+		}
+		params.Sort = value
+	}
+	// Upcoming-first reads naturally for dates; newest and most popular first
+	// for the others.
+	params.Desc = params.Sort != "start_date"
+	switch strings.ToLower(q.Get("order")) {
+	case "":
+	case "asc":
+		params.Desc = false
+	case "desc":
+		params.Desc = true
+	default:
+		return nil, &ParamError{Parameter: "order", Message: "must be asc or desc"}
+	}
+
+	if params.Page, perr = parsePositiveInt("page", q.Get("page"), 1); perr != nil {
+		return nil, perr
+	}
+	if params.PageSize, perr = parsePositiveInt("page_size", q.Get("page_size"), defaultPageSize); perr != nil {
+		return nil, perr
+	}
+	if params.PageSize > maxPageSize {
+		return nil, &ParamError{Parameter: "page_size", Message: fmt.Sprintf("must not exceed %d", maxPageSize)}
+	}
+
+	return params, nil
+}
+
+// lowerBound is the earliest end time an event may have to be listed.
+func (p *eventListParams) lowerBound(now time.Time) *time.Time {
+	if p.From != nil {
+		if !p.IncludePast && p.From.Before(now) {
+			return &now
+		}
+		return p.From
+	}
+	if p.IncludePast {
+		return nil
+	}
+	return &now
+}
+
+// expansionWindow bounds recurring event expansion, which needs a finite
+// window even when the caller leaves one side open.
+func (p *eventListParams) expansionWindow(now time.Time) (time.Time, time.Time, *ParamError) {
+	from := now
+	if lower := p.lowerBound(now); lower != nil {
+		from = *lower
+	}
+	to := from.Add(defaultExpansionHorizon)
+	if p.To != nil {
+		to = *p.To
+	}
+	if to.Sub(from) > maxOccurrenceWindow {
+		return from, to, &ParamError{Parameter: "to", Message: "date window must not exceed 366 days"}
+	}
+	return from, to, nil
+}
+
+func (p *eventListParams) orderClause() string {
+	direction := "ASC"
+	if p.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("%s %s, id %s", sortColumns[p.Sort], direction, direction)
+}
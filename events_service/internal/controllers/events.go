@@ -3,59 +3,66 @@ package controllers
 import (
 	"diplomaPorject/backend/events_service/internal/models"
 	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/audit"
+	"diplomaPorject/backend/shared/gallery"
+	"diplomaPorject/backend/shared/geo"
+	"diplomaPorject/backend/shared/media"
+	"diplomaPorject/backend/shared/update"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
-	"io"
+	"gorm.io/gorm"
 	"log"
 	"math"
 	"net/http"
-	"os"
-	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
-type EventRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	StartDate   string `json:"start_date"`
-	EndDate     string `json:"end_date"`
-	Location    string `json:"location"`
-	Capacity    int    `json:"capacity"`
-	Category    string `json:"category"`
-	ImageURL    string `json:"image_url"`
-}
-
-func generateRandomFilename(originalFilename string) string {
-	timestamp := time.Now().UnixNano()
-	return fmt.Sprintf("%d_%s", timestamp, filepath.Base(originalFilename))
-}
-
-func uploadImage(file io.Reader, filename string) (string, error) {
-	uploadDir := "/app/uploads/events" // ✅ Correct directory for event images
-
-	// Create the events directory if it doesn't exist
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %v", err)
+// eventFromFields validates the fields of an event. value returns the raw
+// text of a field, as sent by the create form, an update or an import row.
+func eventFromFields(value func(string) string) (*models.Event, error) {
+	capacity, err := strconv.Atoi(value("capacity"))
+	if err != nil {
+		return nil, errors.New("Invalid capacity value")
 	}
 
-	randomFilename := generateRandomFilename(filename)
-	filePath := filepath.Join(uploadDir, randomFilename)
+	startDate, err := time.Parse(time.RFC3339, value("start_date"))
+	if err != nil {
+		return nil, errors.New("Invalid start date format (expected RFC3339)")
+	}
+	endDate, err := time.Parse(time.RFC3339, value("end_date"))
+	if err != nil {
+		return nil, errors.New("Invalid end date format (expected RFC3339)")
+	}
 
-	dst, err := os.Create(filePath)
+	recurrenceRule, err := normalizeRecurrenceRule(value("recurrence_rule"))
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %v", err)
+		return nil, err
 	}
-	defer dst.Close()
 
-	// Save the file to the target location
-	if _, err := io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("failed to save file: %v", err)
+	latitude, longitude, err := geo.ParseCoordinateFields(value)
+	if err != nil {
+		return nil, err
 	}
 
-	// ✅ Return URL for accessing the image
-	return fmt.Sprintf("/uploads/events/%s", randomFilename), nil
+	return &models.Event{
+		Title:          value("title"),
+		Description:    value("description"),
+		StartDate:      startDate,
+		EndDate:        endDate,
+		Location:       value("location"),
+		Capacity:       capacity,
+		Category:       value("category"),
+		ImageURL:       value("image_url"),
+		RecurrenceRule: recurrenceRule,
+		Latitude:       latitude,
+		Longitude:      longitude,
+	}, nil
 }
 
 // CreateEvent handles event creation with image upload
@@ -82,68 +89,50 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle image file upload
-	var imageURL string
-	file, header, err := r.FormFile("image")
+	event, err := eventFromFields(r.FormValue)
+	if err != nil {
+		log.Printf("Invalid event: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Handle image file upload; further "images" files fill the gallery
+	event.ImageURL = ""
+	var uploads []gallery.Upload
+	file, _, err := r.FormFile("image")
 	if err == nil {
 		defer file.Close()
-		imageURL, err = uploadImage(file, header.Filename)
+		variants, err := media.UploadImage(r, file)
 		if err != nil {
-			log.Printf("Failed to upload image: %v", err)
-			http.Error(w, "Failed to upload image", http.StatusInternalServerError)
+			media.WriteUploadError(w, err)
 			return
 		}
+		uploads = append(uploads, gallery.Upload{Variants: variants})
 	} else {
 		log.Println("No image uploaded. Proceeding without image.")
 	}
-
-	// Extract form fields
-	title := r.FormValue("title")
-	description := r.FormValue("description")
-	location := r.FormValue("location")
-	category := r.FormValue("category")
-	capacityStr := r.FormValue("capacity")
-	startDateStr := r.FormValue("start_date")
-	endDateStr := r.FormValue("end_date")
-
-	// Validate capacity
-	capacity, err := strconv.Atoi(capacityStr)
+	extra, err := gallery.UploadForm(r)
 	if err != nil {
-		log.Printf("Invalid capacity value: %v", err)
-		http.Error(w, "Invalid capacity value", http.StatusBadRequest)
+		media.WriteUploadError(w, err)
 		return
 	}
+	uploads = append(uploads, extra...)
 
-	// Validate dates
-	startDate, err := time.Parse(time.RFC3339, startDateStr)
-	if err != nil {
-		log.Printf("Invalid start date format: %v", err)
-		http.Error(w, "Invalid start date format (expected RFC3339)", http.StatusBadRequest)
-		return
-	}
-
-	endDate, err := time.Parse(time.RFC3339, endDateStr)
-	if err != nil {
-		log.Printf("Invalid end date format: %v", err)
-		http.Error(w, "Invalid end date format (expected RFC3339)", http.StatusBadRequest)
-		return
-	}
-
-	// Create the event object
-	event := models.Event{
-		Title:       title,
-		Description: description,
-		StartDate:   startDate,
-		EndDate:     endDate,
-		Location:    location,
-		Capacity:    capacity,
-		Category:    category,
-		ImageURL:    imageURL,
-		AdminID:     adminID,
-	}
+	event.AdminID = adminID
 
 	// Save to database
-	if err := db.DB.Create(&event).Error; err != nil {
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		images, err := eventGallery.Add(tx, event, uploads)
+		if err != nil {
+			return err
+		}
+		event.Images = images
+		return models.EventAudit.Record(tx, adminID, audit.ActionCreate, event.ID, nil, event)
+	})
+	if err != nil {
 		log.Printf("Failed to create event: %v", err)
 		http.Error(w, "Failed to create event", http.StatusInternalServerError)
 		return
@@ -159,59 +148,196 @@ func GetEvent(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	var event models.Event
-	if err := db.DB.First(&event, id).Error; err != nil {
+	if err := db.DB.Preload("PriceTiers").Preload("Images", gallery.Ordered).First(&event, id).Error; err != nil {
 		http.Error(w, "Not found event", http.StatusNotFound)
 		return
 	}
+	w.Header().Set("ETag", update.EntityTag(event.UpdatedAt))
 	json.NewEncoder(w).Encode(event)
 }
 
-func UpdateEvent(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+var ErrCapacityBelowCount = errors.New("capacity cannot be lower than the number of seats already taken")
 
-	var req EventRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Error with decoding", http.StatusBadRequest)
+// saveEventUpdate copies the editable fields of updated onto an event locked
+// in tx and saves it. Capacity cannot drop below the seats already taken;
+// seats added by a higher capacity go to the waitlist.
+func saveEventUpdate(tx *gorm.DB, event, updated *models.Event) error {
+	if updated.Capacity < event.CurrentCount {
+		return ErrCapacityBelowCount
 	}
+	raised := updated.Capacity > event.Capacity
 
-	var event models.Event
-	if err := db.DB.First(&event, id).Error; err != nil {
-		http.Error(w, "Error with searching event", http.StatusBadRequest)
+	applyEventUpdate(event, updated)
+	if err := tx.Save(event).Error; err != nil {
+		return err
+	}
+	if !raised {
+		return nil
 	}
+	if err := promoteWaitlist(tx, event.ID); err != nil {
+		return err
+	}
+	return tx.Select("current_count").First(event, event.ID).Error
+}
 
-	startDate, err := time.Parse(time.RFC1123Z, req.StartDate)
+// applyEventUpdate copies the editable fields of updated onto event.
+func applyEventUpdate(event, updated *models.Event) {
+	event.Title = updated.Title
+	event.Description = updated.Description
+	event.StartDate = updated.StartDate
+	event.EndDate = updated.EndDate
+	event.Location = updated.Location
+	event.Capacity = updated.Capacity
+	event.Category = updated.Category
+	if updated.ImageURL != event.ImageURL {
+		// The variants belong to the replaced image.
+		event.ImageVariants = nil
+	}
+	event.ImageURL = updated.ImageURL
+	event.RecurrenceRule = updated.RecurrenceRule
+	event.Latitude = updated.Latitude
+	event.Longitude = updated.Longitude
+	event.Sequence++
+}
+
+// UpdateEvent replaces every editable field of an event; fields left out of
+// the request are cleared. Use PatchEvent to change single fields.
+func UpdateEvent(w http.ResponseWriter, r *http.Request) {
+	updateEvent(w, r, false)
+}
+
+// PatchEvent changes only the fields that were sent. It accepts the same
+// JSON or multipart body as UpdateEvent.
+func PatchEvent(w http.ResponseWriter, r *http.Request) {
+	updateEvent(w, r, true)
+}
+
+// updateEvent applies an update request to an event, validating the result
+// like CreateEvent. A multipart request may carry a replacement "image",
+// which becomes the gallery cover. If-Match is checked against the event's
+// ETag to avoid lost updates.
+//
+// The request is checked before the image is uploaded, so a rejected request
+// stores no file, and the upload happens outside the transaction, so the
+// event is not locked while the media service works. The checks run again
+// under the lock in case the event changed during the upload. Should saving
+// still fail, the media service collects the unreferenced file.
+func updateEvent(w http.ResponseWriter, r *http.Request, partial bool) {
+	fields, image, err := update.ReadRequest(r, eventColumns)
 	if err != nil {
-		http.Error(w, "Error with parsing", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if image != nil {
+		defer image.Close()
 	}
+	id := mux.Vars(r)["id"]
 
-	endDate, err := time.Parse(time.RFC1123Z, req.EndDate)
+	event, err := findEventForAdmin(db.DB, id)
+	if err == nil {
+		_, err = checkEventUpdate(r, event, fields, partial, image != nil)
+	}
 	if err != nil {
-		http.Error(w, "Error with parsing", http.StatusBadRequest)
+		writeUpdateError(w, err)
+		return
+	}
+
+	var variants models.ImageVariants
+	if image != nil {
+		if variants, err = media.UploadImage(r, image); err != nil {
+			media.WriteUploadError(w, err)
+			return
+		}
 	}
 
-	event.Title = req.Title
-	event.Description = req.Description
-	event.StartDate = startDate
-	event.EndDate = endDate
-	event.Location = req.Location
-	event.Capacity = req.Capacity
-	event.Category = req.Category
-	event.ImageURL = req.ImageURL
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		event, err = lockEventForAdmin(tx, id)
+		if err != nil {
+			return err
+		}
+		updated, err := checkEventUpdate(r, event, fields, partial, image != nil)
+		if err != nil {
+			return err
+		}
+		before, err := audit.Snapshot(event)
+		if err != nil {
+			return err
+		}
 
-	if err := db.DB.Save(&event).Error; err != nil {
-		http.Error(w, "Cant update event", http.StatusBadRequest)
+		if err := saveEventUpdate(tx, event, updated); err != nil {
+			return err
+		}
+		if variants != nil {
+			if err := eventGallery.ReplaceCover(tx, event, variants); err != nil {
+				return err
+			}
+		}
+		if err := tx.Preload("PriceTiers").Preload("Images", gallery.Ordered).First(event, event.ID).Error; err != nil {
+			return err
+		}
+		return models.EventAudit.Record(tx, access.AdminID(r), audit.ActionUpdate, event.ID, before, event)
+	})
+	if err != nil {
+		writeUpdateError(w, err)
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", update.EntityTag(event.UpdatedAt))
 	json.NewEncoder(w).Encode(event)
 }
 
+// checkEventUpdate checks that the admin may apply the update to event in its
+// current state and returns the event as the update would leave it.
+func checkEventUpdate(r *http.Request, event *models.Event, fields map[string]string, partial, hasImage bool) (*models.Event, error) {
+	if !access.MayEdit(r, event.AdminID) {
+		return nil, access.ErrNotOwner
+	}
+	if !update.IfMatch(r, event.UpdatedAt) {
+		return nil, update.ErrPreconditionFailed
+	}
+
+	merged := fields
+	if partial {
+		var err error
+		if merged, err = update.MergeFields(eventColumns, eventRecord(event), fields); err != nil {
+			return nil, err
+		}
+	}
+	if hasImage {
+		// The uploaded file takes the place of any image_url sent.
+		merged["image_url"] = event.ImageURL
+	}
+	updated, err := eventFromFields(func(name string) string { return merged[name] })
+	if err != nil {
+		return nil, &update.InvalidError{Err: err}
+	}
+	if updated.Capacity < event.CurrentCount {
+		return nil, ErrCapacityBelowCount
+	}
+	return updated, nil
+}
+
 func DeleteEvent(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-	err := db.DB.Delete(&models.Event{}, id).Error
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		event, err := lockEventForAdmin(tx, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(event).Error; err != nil {
+			return err
+		}
+		return models.EventAudit.Record(tx, access.AdminID(r), audit.ActionDelete, event.ID, event, nil)
+	})
+	if errors.Is(err, ErrEventNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Cant delete event", http.StatusBadRequest)
+		log.Printf("Failed to delete event: %v", err)
+		http.Error(w, "Cant delete event", http.StatusInternalServerError)
+		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -231,23 +357,56 @@ func ListEvents(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(events)
 }
 func PublishEvent(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	if err := db.DB.Model(&models.Event{}).Where("id = ?", id).Update("is_published", true).Error; err != nil {
-		http.Error(w, "Failed to publish event", http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
+	setEventPublished(w, r, true)
 }
 
 func UnpublishEvent(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	setEventPublished(w, r, false)
+}
+
+// setEventPublished publishes or unpublishes an event by hand, which also
+// clears the matching scheduled time. A cancelled event stays unpublished.
+func setEventPublished(w http.ResponseWriter, r *http.Request, published bool) {
+	action, scheduleColumn := audit.ActionPublish, "publish_at"
+	if !published {
+		action, scheduleColumn = audit.ActionUnpublish, "unpublish_at"
+	}
 
-	if err := db.DB.Model(&models.Event{}).Where("id = ?", id).Update("is_published", false).Error; err != nil {
-		http.Error(w, "Failed to unpublish event", http.StatusInternalServerError)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		event, err := lockEventForAdmin(tx, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+		if published && event.CancelledAt != nil {
+			return ErrEventCancelled
+		}
+		before, err := audit.Snapshot(event)
+		if err != nil {
+			return err
+		}
+
+		event.IsPublished = published
+		if published {
+			event.PublishAt = nil
+		} else {
+			event.UnpublishAt = nil
+		}
+		if err := tx.Model(event).Select("is_published", scheduleColumn).Updates(event).Error; err != nil {
+			return err
+		}
+		return models.EventAudit.Record(tx, access.AdminID(r), action, event.ID, before, event)
+	})
+	if errors.Is(err, ErrEventNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrEventCancelled) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to %s event: %v", action, err)
+		http.Error(w, fmt.Sprintf("Failed to %s event", action), http.StatusInternalServerError)
 		return
 	}
 
@@ -255,31 +414,19 @@ func UnpublishEvent(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListPublishedEvents(w http.ResponseWriter, r *http.Request) {
-	var events []models.Event
-	query := db.DB.Where("is_published = ?", true)
-
-	if category := r.URL.Query().Get("category"); category != "" {
-		query = query.Where("category = ?", category)
+	params, perr := parseEventListParams(r)
+	if perr != nil {
+		writeParamError(w, perr)
+		return
 	}
 
-	page := 1
-	pageSize := 10
-	if pageParam := r.URL.Query().Get("page"); pageParam != "" {
-		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
-			page = p
-		}
+	events, totalCount, perr, err := listPublishedEvents(params, time.Now())
+	if perr != nil {
+		writeParamError(w, perr)
+		return
 	}
-
-	offset := (page - 1) * pageSize
-
-	var totalCount int64
-	query.Model(&models.Event{}).Count(&totalCount)
-
-	if err := query.
-		Order("start_date ASC"). // Sort by start date
-		Offset(offset).
-		Limit(pageSize).
-		Find(&events).Error; err != nil {
+	if err != nil {
+		log.Printf("Failed to list events: %v", err)
 		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
 		return
 	}
@@ -293,11 +440,114 @@ func ListPublishedEvents(w http.ResponseWriter, r *http.Request) {
 	}{
 		Events:     events,
 		Total:      totalCount,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalPages: int(math.Ceil(float64(totalCount) / float64(pageSize))),
+		Page:       params.Page,
+		PageSize:   params.PageSize,
+		TotalPages: int(math.Ceil(float64(totalCount) / float64(params.PageSize))),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// filterPublishedEvents applies the attribute filters shared by one-off and
+// recurring events.
+func filterPublishedEvents(params *eventListParams) *gorm.DB {
+	query := db.DB.Model(&models.Event{}).Where("is_published = ?", true)
+
+	if params.Category != "" {
+		query = query.Where("category = ?", params.Category)
+	}
+	if params.Location != "" {
+		query = query.Where("location ILIKE ?", "%"+escapeLike(params.Location)+"%")
+	}
+	if params.Query != "" {
+		pattern := "%" + escapeLike(params.Query) + "%"
+		query = query.Where("(title ILIKE ? OR description ILIKE ?)", pattern, pattern)
+	}
+	return query
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// listPublishedEvents merges one-off events and expanded occurrences of
+// recurring events, sorted and paginated as requested. Only the first
+// offset+page_size one-off events are loaded since nothing after them can
+// land on the requested page.
+func listPublishedEvents(params *eventListParams, now time.Time) ([]models.Event, int64, *ParamError, error) {
+	offset := (params.Page - 1) * params.PageSize
+	limit := offset + params.PageSize
+
+	single := filterPublishedEvents(params).Where("recurrence_rule = ?", "")
+	if lower := params.lowerBound(now); lower != nil {
+		single = single.Where("end_date >= ?", *lower)
+	}
+	if params.To != nil {
+		single = single.Where("start_date < ?", *params.To)
+	}
+
+	var total int64
+	if err := single.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, nil, err
+	}
+
+	var events []models.Event
+	if err := single.Order(params.orderClause()).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, nil, err
+	}
+
+	from, to, perr := params.expansionWindow(now)
+	if perr != nil {
+		return nil, 0, perr, nil
+	}
+
+	var series []models.Event
+	if err := filterPublishedEvents(params).
+		Where("recurrence_rule <> ?", "").
+		Where("start_date < ?", to).
+		Find(&series).Error; err != nil {
+		return nil, 0, nil, err
+	}
+	occurrences, err := expandSeries(series, from, to)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	events = append(events, occurrences...)
+	total += int64(len(occurrences))
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventLess(&events[i], &events[j], params)
+	})
+
+	if offset >= len(events) {
+		return []models.Event{}, total, nil, nil
+	}
+	if limit > len(events) {
+		limit = len(events)
+	}
+	return events[offset:limit], total, nil, nil
+}
+
+// eventLess mirrors orderClause for merging in memory.
+func eventLess(a, b *models.Event, params *eventListParams) bool {
+	var cmp int
+	switch params.Sort {
+	case "created_at":
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	case "popularity":
+		cmp = a.CurrentCount - b.CurrentCount
+	default:
+		cmp = a.StartDate.Compare(b.StartDate)
+	}
+	if cmp == 0 {
+		cmp = int(a.ID) - int(b.ID)
+		if cmp == 0 {
+			cmp = a.StartDate.Compare(b.StartDate)
+		}
+	}
+	if params.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
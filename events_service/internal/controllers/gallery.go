@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/shared/gallery"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// findEventForAdmin loads an event whether or not it is published.
+func findEventForAdmin(tx *gorm.DB, id string) (*models.Event, error) {
+	var event models.Event
+	err := tx.First(&event, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// lockEventForAdmin locks an event for an admin change, published or not.
+func lockEventForAdmin(tx *gorm.DB, id string) (*models.Event, error) {
+	return findEventForAdmin(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+// eventGallery keeps event images in event_images. The event cover is what
+// listings and tickets show.
+var eventGallery = gallery.Gallery[models.EventImage, *models.EventImage]{
+	OwnerColumn: "event_id",
+	New: func(eventID uint, image gallery.Image) models.EventImage {
+		return models.EventImage{Image: image, EventID: eventID}
+	},
+}
+
+// EventImages serves /admin/events/{id}/images, for drafts as well as
+// published events.
+var EventImages = gallery.Handlers[models.EventImage, *models.EventImage]{
+	Gallery: eventGallery,
+	DB:      func() *gorm.DB { return db.DB },
+	Load: func(tx *gorm.DB, id string) (gallery.Owner, error) {
+		event, err := findEventForAdmin(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		return event, nil
+	},
+	NotFound: ErrEventNotFound,
+}
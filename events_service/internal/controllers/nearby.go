@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/shared/geo"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type NearbyEvent struct {
+	models.Event
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// findNearbyEvents narrows candidates with an indexed bounding-box query and
+// then ranks them by exact distance. Events that have already ended are left
+// out.
+func findNearbyEvents(query *geo.NearbyQuery, excludeID uint) ([]NearbyEvent, error) {
+	box := geo.BoundingBoxAround(query.Lat, query.Lng, query.RadiusKm)
+
+	var candidates []models.Event
+	if err := db.DB.Where("is_published = ?", true).
+		Where("latitude BETWEEN ? AND ?", box.MinLat, box.MaxLat).
+		Where("longitude BETWEEN ? AND ?", box.MinLng, box.MaxLng).
+		Where("(end_date >= ? OR recurrence_rule <> ?)", time.Now(), "").
+		Where("id <> ?", excludeID).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]NearbyEvent, 0, len(candidates))
+	for _, event := range candidates {
+		distance := geo.DistanceKm(query.Lat, query.Lng, *event.Latitude, *event.Longitude)
+		if distance <= query.RadiusKm {
+			results = append(results, NearbyEvent{Event: event, DistanceKm: distance})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].DistanceKm < results[j].DistanceKm
+	})
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+func ListNearbyEvents(w http.ResponseWriter, r *http.Request) {
+	query, err := geo.ParseNearbyQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := findNearbyEvents(query, 0)
+	if err != nil {
+		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Events   []NearbyEvent `json:"events"`
+		Count    int           `json:"count"`
+		RadiusKm float64       `json:"radius_km"`
+	}{
+		Events:   results,
+		Count:    len(results),
+		RadiusKm: query.RadiusKm,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/events_service/utils/recurrence"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxOccurrenceWindow bounds how far a single request may expand recurring
+// events.
+const maxOccurrenceWindow = 366 * 24 * time.Hour
+
+var (
+	ErrNotRecurring      = errors.New("event is not recurring")
+	ErrOccurrenceMissing = errors.New("no such occurrence")
+)
+
+// normalizeRecurrenceRule validates an RRULE from a request and returns its
+// canonical form. An empty value means a one-off event.
+func normalizeRecurrenceRule(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	rule, err := recurrence.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	return rule.String(), nil
+}
+
+func loadOverrides(eventIDs []uint) (map[uint]map[int64]models.EventOccurrenceOverride, error) {
+	result := make(map[uint]map[int64]models.EventOccurrenceOverride)
+	if len(eventIDs) == 0 {
+		return result, nil
+	}
+
+	var overrides []models.EventOccurrenceOverride
+	if err := db.DB.Where("event_id IN ?", eventIDs).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		if result[override.EventID] == nil {
+			result[override.EventID] = make(map[int64]models.EventOccurrenceOverride)
+		}
+		result[override.EventID][override.OccurrenceStart.Unix()] = override
+	}
+	return result, nil
+}
+
+func applyOverride(occurrence *models.Event, override models.EventOccurrenceOverride) {
+	if override.StartDate != nil {
+		occurrence.StartDate = *override.StartDate
+	}
+	if override.EndDate != nil {
+		occurrence.EndDate = *override.EndDate
+	}
+	if override.Title != nil {
+		occurrence.Title = *override.Title
+	}
+	if override.Description != nil {
+		occurrence.Description = *override.Description
+	}
+	if override.Location != nil {
+		occurrence.Location = *override.Location
+	}
+}
+
+// expandSeries turns recurring events into one entry per occurrence that
+// overlaps [from, to), with per-occurrence overrides applied and cancelled
+// occurrences left out.
+func expandSeries(series []models.Event, from, to time.Time) ([]models.Event, error) {
+	ids := make([]uint, 0, len(series))
+	for _, event := range series {
+		ids = append(ids, event.ID)
+	}
+	overrides, err := loadOverrides(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var occurrences []models.Event
+	for _, event := range series {
+		rule, err := recurrence.Parse(event.RecurrenceRule)
+		if err != nil {
+			log.Printf("Skipping event %d with invalid recurrence rule: %v", event.ID, err)
+			continue
+		}
+
+		duration := event.EndDate.Sub(event.StartDate)
+		for _, start := range rule.Between(event.StartDate, from.Add(-duration), to) {
+			occurrence := event
+			occurrenceStart := start
+			occurrence.OccurrenceStart = &occurrenceStart
+			occurrence.StartDate = start
+			occurrence.EndDate = start.Add(duration)
+
+			if override, ok := overrides[event.ID][start.Unix()]; ok {
+				if override.Cancelled {
+					continue
+				}
+				applyOverride(&occurrence, override)
+			}
+			if occurrence.EndDate.Before(from) || !occurrence.StartDate.Before(to) {
+				continue
+			}
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences, nil
+}
+
+func parseOccurrenceWindow(r *http.Request, defaultFrom time.Time) (time.Time, time.Time, error) {
+	from, to := defaultFrom, defaultFrom.Add(90*24*time.Hour)
+
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, to, errors.New("invalid from date (expected RFC3339)")
+		}
+		from = parsed
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, to, errors.New("invalid to date (expected RFC3339)")
+		}
+		to = parsed
+	}
+
+	if !to.After(from) {
+		return from, to, errors.New("to must be after from")
+	}
+	if to.Sub(from) > maxOccurrenceWindow {
+		return from, to, errors.New("date window must not exceed 366 days")
+	}
+	return from, to, nil
+}
+
+type occurrenceView struct {
+	OccurrenceStart time.Time `json:"occurrence_start"`
+	StartDate       time.Time `json:"start_date"`
+	EndDate         time.Time `json:"end_date"`
+	Title           string    `json:"title"`
+	Location        string    `json:"location"`
+	Cancelled       bool      `json:"cancelled"`
+	OverrideID      *uint     `json:"override_id,omitempty"`
+}
+
+// ListEventOccurrences shows the admin every occurrence of a series in the
+// requested window, including cancelled ones.
+func ListEventOccurrences(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var event models.Event
+	if err := db.DB.First(&event, id).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+	rule, err := recurrence.Parse(event.RecurrenceRule)
+	if err != nil {
+		http.Error(w, ErrNotRecurring.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := parseOccurrenceWindow(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	overrides, err := loadOverrides([]uint{event.ID})
+	if err != nil {
+		http.Error(w, "Failed to fetch occurrences", http.StatusInternalServerError)
+		return
+	}
+
+	duration := event.EndDate.Sub(event.StartDate)
+	views := []occurrenceView{}
+	for _, start := range rule.Between(event.StartDate, from, to) {
+		occurrence := event
+		occurrence.StartDate = start
+		occurrence.EndDate = start.Add(duration)
+
+		view := occurrenceView{OccurrenceStart: start}
+		if override, ok := overrides[event.ID][start.Unix()]; ok {
+			applyOverride(&occurrence, override)
+			view.Cancelled = override.Cancelled
+			view.OverrideID = &override.ID
+		}
+		view.StartDate = occurrence.StartDate
+		view.EndDate = occurrence.EndDate
+		view.Title = occurrence.Title
+		view.Location = occurrence.Location
+		views = append(views, view)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+type OccurrenceOverrideRequest struct {
+	OccurrenceStart string  `json:"occurrence_start"`
+	Cancelled       bool    `json:"cancelled"`
+	StartDate       *string `json:"start_date"`
+	EndDate         *string `json:"end_date"`
+	Title           *string `json:"title"`
+	Description     *string `json:"description"`
+	Location        *string `json:"location"`
+}
+
+func parseOptionalTime(value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// SaveOccurrenceOverride cancels or modifies a single occurrence of a
+// recurring event without touching the rest of the series.
+func SaveOccurrenceOverride(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req OccurrenceOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	occurrenceStart, err := time.Parse(time.RFC3339, req.OccurrenceStart)
+	if err != nil {
+		http.Error(w, "Invalid occurrence_start (expected RFC3339)", http.StatusBadRequest)
+		return
+	}
+	startDate, err := parseOptionalTime(req.StartDate)
+	if err != nil {
+		http.Error(w, "Invalid start date format (expected RFC3339)", http.StatusBadRequest)
+		return
+	}
+	endDate, err := parseOptionalTime(req.EndDate)
+	if err != nil {
+		http.Error(w, "Invalid end date format (expected RFC3339)", http.StatusBadRequest)
+		return
+	}
+
+	var override models.EventOccurrenceOverride
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+			return ErrEventNotFound
+		}
+		rule, err := recurrence.Parse(event.RecurrenceRule)
+		if err != nil {
+			return ErrNotRecurring
+		}
+		if !rule.Includes(event.StartDate, occurrenceStart) {
+			return ErrOccurrenceMissing
+		}
+
+		err = tx.Where("event_id = ? AND occurrence_start = ?", event.ID, occurrenceStart).First(&override).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		override.EventID = event.ID
+		override.OccurrenceStart = occurrenceStart
+		override.Cancelled = req.Cancelled
+		override.StartDate = startDate
+		override.EndDate = endDate
+		override.Title = req.Title
+		override.Description = req.Description
+		override.Location = req.Location
+		if err := tx.Save(&override).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&event).Update("sequence", gorm.Expr("sequence + 1")).Error
+	})
+	if err != nil {
+		writeOccurrenceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(override)
+}
+
+// DeleteOccurrenceOverride restores an occurrence to what the series rule
+// generates.
+func DeleteOccurrenceOverride(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("id = ? AND event_id = ?", vars["overrideId"], vars["id"]).
+			Delete(&models.EventOccurrenceOverride{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOccurrenceMissing
+		}
+		return tx.Model(&models.Event{}).Where("id = ?", vars["id"]).
+			Update("sequence", gorm.Expr("sequence + 1")).Error
+	})
+	if err != nil {
+		writeOccurrenceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeOccurrenceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrEventNotFound), errors.Is(err, ErrOccurrenceMissing):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNotRecurring):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Occurrence update failed: %v", err)
+		http.Error(w, "Failed to update occurrence", http.StatusInternalServerError)
+	}
+}
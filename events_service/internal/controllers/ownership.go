@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/shared/access"
+	"net/http"
+)
+
+// eventOwner returns the ID of the admin who created the event.
+func eventOwner(id string) (uint, error) {
+	var event models.Event
+	if err := db.DB.Select("id", "admin_id").First(&event, id).Error; err != nil {
+		return 0, ErrEventNotFound
+	}
+	return event.AdminID, nil
+}
+
+// OwnerOnly guards a handler of an "/{id}" route that changes the event,
+// enforcing the edit policy before the handler runs.
+func OwnerOnly(next http.HandlerFunc) http.HandlerFunc {
+	return access.OwnerOnly(eventOwner, next)
+}
@@ -0,0 +1,511 @@
+package controllers
+
+import (
+	"context"
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/internal/payments"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/audit"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrPriceTierMissing = errors.New("price tier not found for this event")
+	ErrPaymentMissing   = errors.New("payment not found")
+	ErrRefundClaimed    = errors.New("payment is not paid or is already being refunded")
+)
+
+const (
+	// promoProviderName marks payments fully covered by a promo code, which
+	// never reach a payment provider.
+	promoProviderName = "promo"
+	// freeProviderName marks payments that cost nothing without a promo
+	// code, such as a group discount covering the whole price.
+	freeProviderName = "free"
+	// maxCheckoutSeats limits how many seats one group checkout can buy.
+	maxCheckoutSeats = 20
+)
+
+// CheckoutRequest buys Seats seats (one if left out) of a price tier.
+type CheckoutRequest struct {
+	PriceTierID uint   `json:"price_tier_id"`
+	Seats       int    `json:"seats"`
+	PromoCode   string `json:"promo_code"`
+}
+
+// Checkout starts a payment for a paid event. No seat is held while the user
+// pays; the registration is only created once the provider confirms payment,
+// and the payment is refunded if the event filled up in the meantime. A promo
+// code covering the whole price registers the user straight away. Buying
+// several seats at once earns the event's group discount, which is applied
+// before the promo code.
+func Checkout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized - User ID missing", http.StatusUnauthorized)
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	var req CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Seats == 0 {
+		req.Seats = 1
+	}
+	if req.Seats < 1 || req.Seats > maxCheckoutSeats {
+		http.Error(w, fmt.Sprintf("seats must be between 1 and %d", maxCheckoutSeats), http.StatusBadRequest)
+		return
+	}
+
+	provider := payments.Active()
+	var payment models.Payment
+	var event *models.Event
+	var registration *models.EventRegistration
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		event, err = lockEvent(tx, id)
+		if err != nil {
+			return err
+		}
+
+		var tier models.PriceTier
+		if err := tx.Where("event_id = ?", event.ID).First(&tier, req.PriceTierID).Error; err != nil {
+			return ErrPriceTierMissing
+		}
+
+		var existing int64
+		if err := tx.Model(&models.EventRegistration{}).
+			Where("event_id = ? AND user_id = ? AND status <> ?", event.ID, userID, models.RegistrationStatusCancelled).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyRegistered
+		}
+		room, err := hasRoom(tx, event, req.Seats)
+		if err != nil {
+			return err
+		}
+		if !room {
+			return ErrEventFull
+		}
+
+		percent, err := groupDiscountPercent(tx, event.ID, req.Seats)
+		if err != nil {
+			return err
+		}
+		subtotal := tier.AmountKZT * int64(req.Seats)
+		payment = models.Payment{
+			EventID:          event.ID,
+			UserID:           userID,
+			PriceTierID:      tier.ID,
+			Seats:            req.Seats,
+			GroupDiscountKZT: subtotal * percent / 100,
+			Currency:         "KZT",
+			Provider:         provider.Name(),
+			Status:           models.PaymentStatusPending,
+		}
+		payment.AmountKZT = subtotal - payment.GroupDiscountKZT
+
+		var redemption *models.PromoRedemption
+		if req.PromoCode != "" {
+			code, used, err := redeemPromoCode(tx, req.PromoCode, event, userID, payment.AmountKZT)
+			if err != nil {
+				return err
+			}
+			redemption = used
+			payment.PromoCodeID = &code.ID
+			payment.DiscountKZT = redemption.DiscountKZT
+			payment.AmountKZT -= redemption.DiscountKZT
+		}
+
+		if payment.AmountKZT == 0 {
+			registration, err = seatUser(tx, event, userID, req.Seats, false)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			payment.Provider = freeProviderName
+			if redemption != nil {
+				payment.Provider = promoProviderName
+			}
+			payment.Status = models.PaymentStatusPaid
+			payment.PaidAt = &now
+			payment.RegistrationID = &registration.ID
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+
+		if redemption == nil {
+			return nil
+		}
+		return tx.Model(redemption).Updates(map[string]interface{}{
+			"payment_id":      payment.ID,
+			"registration_id": payment.RegistrationID,
+		}).Error
+	})
+	if errors.Is(err, ErrPriceTierMissing) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writePromoError(w, err)
+		return
+	}
+
+	if registration != nil {
+		writeCheckoutResponse(w, &payment, "", registration)
+		return
+	}
+
+	session, err := provider.CreateCheckout(r.Context(), payments.CheckoutRequest{
+		PaymentID:   payment.ID,
+		EventID:     payment.EventID,
+		UserID:      userID,
+		AmountKZT:   payment.AmountKZT,
+		Description: event.Title,
+	})
+	if err != nil {
+		log.Printf("Checkout for payment %d failed: %v", payment.ID, err)
+		db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&payment).Update("status", models.PaymentStatusFailed).Error; err != nil {
+				return err
+			}
+			return releasePromoRedemption(tx, &payment)
+		})
+		http.Error(w, "Payment provider unavailable", http.StatusBadGateway)
+		return
+	}
+	if err := db.DB.Model(&payment).Update("provider_ref", session.ProviderRef).Error; err != nil {
+		http.Error(w, "Failed to start checkout", http.StatusInternalServerError)
+		return
+	}
+
+	writeCheckoutResponse(w, &payment, session.RedirectURL, nil)
+}
+
+func writeCheckoutResponse(w http.ResponseWriter, payment *models.Payment, checkoutURL string, registration *models.EventRegistration) {
+	response := struct {
+		PaymentID        uint                      `json:"payment_id"`
+		Status           string                    `json:"status"`
+		CheckoutURL      string                    `json:"checkout_url,omitempty"`
+		Seats            int                       `json:"seats"`
+		AmountKZT        int64                     `json:"amount_kzt"`
+		GroupDiscountKZT int64                     `json:"group_discount_kzt"`
+		DiscountKZT      int64                     `json:"discount_kzt"`
+		Currency         string                    `json:"currency"`
+		Registration     *models.EventRegistration `json:"registration,omitempty"`
+	}{
+		PaymentID:        payment.ID,
+		Status:           payment.Status,
+		CheckoutURL:      checkoutURL,
+		Seats:            payment.Seats,
+		AmountKZT:        payment.AmountKZT,
+		GroupDiscountKZT: payment.GroupDiscountKZT,
+		DiscountKZT:      payment.DiscountKZT,
+		Currency:         payment.Currency,
+		Registration:     registration,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// settlePayment applies a verified provider result. It is idempotent: results
+// for payments that are no longer pending are ignored, so providers may retry
+// webhooks freely. A paid payment that cannot be turned into a registration
+// is refunded.
+func settlePayment(provider payments.PaymentProvider, providerRef, status string) (*models.Payment, error) {
+	var payment models.Payment
+	needsRefund := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_ref = ?", provider.Name(), providerRef).
+			First(&payment).Error; err != nil {
+			return ErrPaymentMissing
+		}
+		if payment.Status != models.PaymentStatusPending {
+			return nil
+		}
+		if status != payments.StatusPaid {
+			payment.Status = models.PaymentStatusFailed
+			if err := releasePromoRedemption(tx, &payment); err != nil {
+				return err
+			}
+			return tx.Save(&payment).Error
+		}
+
+		now := time.Now()
+		payment.Status = models.PaymentStatusPaid
+		payment.PaidAt = &now
+
+		var event models.Event
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, payment.EventID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var registration *models.EventRegistration
+		if err == nil && event.IsPublished && event.CancelledAt == nil {
+			registration, err = seatUser(tx, &event, payment.UserID, payment.Seats, false)
+			if err != nil && !errors.Is(err, ErrEventFull) && !errors.Is(err, ErrAlreadyRegistered) {
+				return err
+			}
+		}
+		if registration == nil {
+			log.Printf("Payment %d cannot be seated, refunding: %v", payment.ID, err)
+			needsRefund = true
+			if err := releasePromoRedemption(tx, &payment); err != nil {
+				return err
+			}
+			return tx.Save(&payment).Error
+		}
+
+		payment.RegistrationID = &registration.ID
+		if err := tx.Save(&payment).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PromoRedemption{}).Where("payment_id = ?", payment.ID).
+			Update("registration_id", registration.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if needsRefund {
+		if err := refundPayment(context.Background(), &payment); err != nil {
+			log.Printf("Refund for payment %d failed: %v", payment.ID, err)
+		}
+	}
+	return &payment, nil
+}
+
+// refundPayment refunds a paid payment through the provider that took it and
+// gives back the promo code use it took. The payment is claimed first by
+// moving it from paid to refunding, so concurrent callers cannot refund it
+// twice; those that lose get ErrRefundClaimed. A failed refund returns the
+// payment to paid so that it can be retried.
+func refundPayment(ctx context.Context, payment *models.Payment) error {
+	claim := db.DB.Model(payment).Where("status = ?", models.PaymentStatusPaid).
+		Update("status", models.PaymentStatusRefunding)
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return ErrRefundClaimed
+	}
+
+	// Payments covered by discounts or a promo code charged nothing.
+	if payment.AmountKZT > 0 {
+		if err := refundWithProvider(ctx, payment); err != nil {
+			if releaseErr := db.DB.Model(payment).Where("status = ?", models.PaymentStatusRefunding).
+				Update("status", models.PaymentStatusPaid).Error; releaseErr != nil {
+				log.Printf("Payment %d is left refunding: %v", payment.ID, releaseErr)
+			}
+			payment.Status = models.PaymentStatusPaid
+			return err
+		}
+	}
+
+	now := time.Now()
+	payment.Status = models.PaymentStatusRefunded
+	payment.RefundedAt = &now
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(payment).Updates(map[string]interface{}{
+			"status":      payment.Status,
+			"refunded_at": payment.RefundedAt,
+		}).Error; err != nil {
+			return err
+		}
+		return releasePromoRedemption(tx, payment)
+	})
+}
+
+func refundWithProvider(ctx context.Context, payment *models.Payment) error {
+	provider := payments.Active()
+	if payment.Provider != provider.Name() {
+		return fmt.Errorf("payment was taken by provider %q, which is not configured", payment.Provider)
+	}
+	if payment.ProviderRef == nil {
+		return errors.New("payment has no provider reference")
+	}
+	return provider.Refund(ctx, *payment.ProviderRef, payment.AmountKZT)
+}
+
+// PaymentWebhook receives payment results from the provider named in the path.
+func PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := payments.Active()
+	if mux.Vars(r)["provider"] != provider.Name() {
+		http.Error(w, "Unknown payment provider", http.StatusNotFound)
+		return
+	}
+
+	result, err := provider.ParseWebhook(r)
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payment, err := settlePayment(provider, result.ProviderRef, result.Status)
+	if errors.Is(err, ErrPaymentMissing) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Webhook for %s failed: %v", result.ProviderRef, err)
+		http.Error(w, "Failed to process payment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
+
+// CompleteFakePayment is the fake provider's payment page: it marks the
+// checkout as paid, as a real provider's webhook would. It is only available
+// when the fake provider is configured, and only to the user who started the
+// checkout.
+func CompleteFakePayment(w http.ResponseWriter, r *http.Request) {
+	provider := payments.Active()
+	if provider.Name() != payments.FakeProviderName {
+		http.NotFound(w, r)
+		return
+	}
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized - User ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	ref := mux.Vars(r)["ref"]
+	var count int64
+	if err := db.DB.Model(&models.Payment{}).
+		Where("provider = ? AND provider_ref = ? AND user_id = ?", provider.Name(), ref, userID).
+		Count(&count).Error; err != nil {
+		http.Error(w, "Failed to fetch payment", http.StatusInternalServerError)
+		return
+	}
+	if count == 0 {
+		http.Error(w, ErrPaymentMissing.Error(), http.StatusNotFound)
+		return
+	}
+
+	payment, err := settlePayment(provider, ref, payments.StatusPaid)
+	if errors.Is(err, ErrPaymentMissing) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Fake payment failed: %v", err)
+		http.Error(w, "Failed to process payment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
+
+// CancelEvent cancels an event for good: it is unpublished, every
+// registration is cancelled, tickets are revoked, promo code uses are given
+// back and paid payments are refunded. Calling it again retries refunds that
+// failed.
+func CancelEvent(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var event models.Event
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+			return ErrEventNotFound
+		}
+		if event.CancelledAt != nil {
+			return nil
+		}
+		before, err := audit.Snapshot(&event)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		event.CancelledAt = &now
+		event.IsPublished = false
+		event.CurrentCount = 0
+		event.Sequence++
+		if err := tx.Save(&event).Error; err != nil {
+			return err
+		}
+		if err := models.EventAudit.Record(tx, access.AdminID(r), audit.ActionCancel, event.ID, before, &event); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.EventRegistration{}).
+			Where("event_id = ? AND status IN ?", event.ID,
+				[]string{models.RegistrationStatusRegistered, models.RegistrationStatusWaitlisted}).
+			Updates(map[string]interface{}{
+				"status":   models.RegistrationStatusCancelled,
+				"position": 0,
+			}).Error; err != nil {
+			return err
+		}
+		if err := releaseRedemptions(tx, "event_id = ?", event.ID); err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("event_id = ?", event.ID).Delete(&models.Ticket{}).Error
+	})
+	if err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	var paid []models.Payment
+	if err := db.DB.Where("event_id = ? AND status = ?", event.ID, models.PaymentStatusPaid).
+		Find(&paid).Error; err != nil {
+		http.Error(w, "Failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+
+	refunded, failed := 0, 0
+	for i := range paid {
+		err := refundPayment(r.Context(), &paid[i])
+		if errors.Is(err, ErrRefundClaimed) {
+			// Refunded by a concurrent call.
+			continue
+		}
+		if err != nil {
+			log.Printf("Refund for payment %d failed: %v", paid[i].ID, err)
+			failed++
+			continue
+		}
+		refunded++
+	}
+
+	response := struct {
+		Event         models.Event `json:"event"`
+		Refunded      int          `json:"refunded"`
+		RefundsFailed int          `json:"refunds_failed"`
+	}{
+		Event:         event,
+		Refunded:      refunded,
+		RefundsFailed: failed,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+// PriceTierRequest carries amounts in minor units (tiyn, cents).
+type PriceTierRequest struct {
+	Name       string `json:"name"`
+	AmountKZT  int64  `json:"amount_kzt"`
+	DisplayUSD *int64 `json:"display_usd"`
+	DisplayEUR *int64 `json:"display_eur"`
+}
+
+func (req *PriceTierRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if req.AmountKZT <= 0 {
+		return errors.New("amount_kzt must be positive")
+	}
+	if (req.DisplayUSD != nil && *req.DisplayUSD < 0) || (req.DisplayEUR != nil && *req.DisplayEUR < 0) {
+		return errors.New("display prices must not be negative")
+	}
+	return nil
+}
+
+func hasPriceTiers(tx *gorm.DB, eventID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.PriceTier{}).Where("event_id = ?", eventID).Count(&count).Error
+	return count > 0, err
+}
+
+func ListPriceTiers(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var tiers []models.PriceTier
+	if err := db.DB.Where("event_id = ?", id).Order("amount_kzt ASC").Find(&tiers).Error; err != nil {
+		http.Error(w, "Failed to fetch price tiers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tiers)
+}
+
+func CreatePriceTier(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var event models.Event
+	if err := db.DB.First(&event, id).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+
+	var req PriceTierRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tier := models.PriceTier{
+		EventID:    event.ID,
+		Name:       req.Name,
+		AmountKZT:  req.AmountKZT,
+		DisplayUSD: req.DisplayUSD,
+		DisplayEUR: req.DisplayEUR,
+	}
+	if err := db.DB.Create(&tier).Error; err != nil {
+		http.Error(w, "Failed to create price tier", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tier)
+}
+
+func UpdatePriceTier(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var tier models.PriceTier
+	if err := db.DB.Where("event_id = ?", vars["id"]).First(&tier, vars["tierId"]).Error; err != nil {
+		http.Error(w, "Price tier not found", http.StatusNotFound)
+		return
+	}
+
+	var req PriceTierRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tier.Name = req.Name
+	tier.AmountKZT = req.AmountKZT
+	tier.DisplayUSD = req.DisplayUSD
+	tier.DisplayEUR = req.DisplayEUR
+	if err := db.DB.Save(&tier).Error; err != nil {
+		http.Error(w, "Failed to update price tier", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tier)
+}
+
+func DeletePriceTier(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	result := db.DB.Where("event_id = ?", vars["id"]).Delete(&models.PriceTier{}, vars["tierId"])
+	if result.Error != nil {
+		http.Error(w, "Failed to delete price tier", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Price tier not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type GroupDiscountRequest struct {
+	MinSeats int   `json:"min_seats"`
+	Percent  int64 `json:"percent"`
+}
+
+func (req *GroupDiscountRequest) validate() error {
+	if req.MinSeats < 2 || req.MinSeats > maxCheckoutSeats {
+		return fmt.Errorf("min_seats must be between 2 and %d", maxCheckoutSeats)
+	}
+	if req.Percent < 1 || req.Percent > 100 {
+		return errors.New("percent must be between 1 and 100")
+	}
+	return nil
+}
+
+// groupDiscountPercent returns the largest group discount seats seats earn
+// on the event, or 0.
+func groupDiscountPercent(tx *gorm.DB, eventID uint, seats int) (int64, error) {
+	var percent int64
+	err := tx.Model(&models.GroupDiscount{}).
+		Where("event_id = ? AND min_seats <= ?", eventID, seats).
+		Select("COALESCE(MAX(percent), 0)").Scan(&percent).Error
+	return percent, err
+}
+
+func ListGroupDiscounts(w http.ResponseWriter, r *http.Request) {
+	var discounts []models.GroupDiscount
+	if err := db.DB.Where("event_id = ?", mux.Vars(r)["id"]).Order("min_seats ASC").Find(&discounts).Error; err != nil {
+		http.Error(w, "Failed to fetch group discounts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(discounts)
+}
+
+// SaveGroupDiscount sets the discount for groups of at least min_seats,
+// replacing any discount with the same threshold.
+func SaveGroupDiscount(w http.ResponseWriter, r *http.Request) {
+	var event models.Event
+	if err := db.DB.First(&event, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+
+	var req GroupDiscountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var discount models.GroupDiscount
+	err := db.DB.Where("event_id = ? AND min_seats = ?", event.ID, req.MinSeats).First(&discount).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Failed to save group discount", http.StatusInternalServerError)
+		return
+	}
+	discount.EventID = event.ID
+	discount.MinSeats = req.MinSeats
+	discount.Percent = req.Percent
+	if err := db.DB.Save(&discount).Error; err != nil {
+		http.Error(w, "Failed to save group discount", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(discount)
+}
+
+func DeleteGroupDiscount(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	result := db.DB.Where("event_id = ?", vars["id"]).Delete(&models.GroupDiscount{}, vars["discountId"])
+	if result.Error != nil {
+		http.Error(w, "Failed to delete group discount", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Group discount not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
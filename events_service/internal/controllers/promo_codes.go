@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPromoInvalid       = errors.New("promo code is not valid")
+	ErrPromoExpired       = errors.New("promo code is not valid at this time")
+	ErrPromoNotApplicable = errors.New("promo code does not apply to this event")
+	ErrPromoExhausted     = errors.New("promo code has reached its usage limit")
+	ErrPromoAlreadyUsed   = errors.New("promo code has already been used by this user")
+)
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+type PromoCodeRequest struct {
+	Code          string  `json:"code"`
+	DiscountType  string  `json:"discount_type"`
+	DiscountValue int64   `json:"discount_value"`
+	MaxUses       *int    `json:"max_uses"`
+	ValidFrom     *string `json:"valid_from"`
+	ValidUntil    *string `json:"valid_until"`
+	EventID       *uint   `json:"event_id"`
+	Category      string  `json:"category"`
+	Active        *bool   `json:"active"`
+}
+
+// apply validates the request and copies it onto code.
+func (req *PromoCodeRequest) apply(code *models.PromoCode) error {
+	value := normalizePromoCode(req.Code)
+	if !promoCodePattern.MatchString(value) {
+		return errors.New("code must be 3-32 letters, digits, '-' or '_'")
+	}
+
+	switch req.DiscountType {
+	case models.DiscountTypePercent:
+		if req.DiscountValue < 1 || req.DiscountValue > 100 {
+			return errors.New("percent discount_value must be between 1 and 100")
+		}
+	case models.DiscountTypeFixed:
+		if req.DiscountValue < 1 {
+			return errors.New("fixed discount_value must be positive")
+		}
+	default:
+		return errors.New("discount_type must be percent or fixed")
+	}
+
+	if req.MaxUses != nil && *req.MaxUses < 1 {
+		return errors.New("max_uses must be positive")
+	}
+
+	validFrom, err := parseOptionalTime(req.ValidFrom)
+	if err != nil {
+		return errors.New("invalid valid_from (expected RFC3339)")
+	}
+	validUntil, err := parseOptionalTime(req.ValidUntil)
+	if err != nil {
+		return errors.New("invalid valid_until (expected RFC3339)")
+	}
+	if validFrom != nil && validUntil != nil && !validUntil.After(*validFrom) {
+		return errors.New("valid_until must be after valid_from")
+	}
+
+	if req.EventID != nil {
+		var count int64
+		if err := db.DB.Model(&models.Event{}).Where("id = ?", *req.EventID).Count(&count).Error; err != nil || count == 0 {
+			return errors.New("event_id does not match an event")
+		}
+	}
+
+	code.Code = value
+	code.DiscountType = req.DiscountType
+	code.DiscountValue = req.DiscountValue
+	code.MaxUses = req.MaxUses
+	code.ValidFrom = validFrom
+	code.ValidUntil = validUntil
+	code.EventID = req.EventID
+	code.Category = strings.TrimSpace(req.Category)
+	if req.Active != nil {
+		code.Active = *req.Active
+	}
+	return nil
+}
+
+// discountFor returns the discount in tiyn a code gives on amount.
+func discountFor(code *models.PromoCode, amount int64) int64 {
+	discount := code.DiscountValue
+	if code.DiscountType == models.DiscountTypePercent {
+		discount = amount * code.DiscountValue / 100
+	}
+	if discount > amount {
+		return amount
+	}
+	return discount
+}
+
+// redeemPromoCode checks a code against the event and records one use. The
+// code row is locked for the rest of the transaction, so concurrent
+// redemptions cannot push UsedCount past MaxUses or let a user redeem the
+// code twice.
+func redeemPromoCode(tx *gorm.DB, value string, event *models.Event, userID uint, amount int64) (*models.PromoCode, *models.PromoRedemption, error) {
+	var code models.PromoCode
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ? AND active = ?", normalizePromoCode(value), true).
+		First(&code).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrPromoInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if (code.ValidFrom != nil && now.Before(*code.ValidFrom)) || (code.ValidUntil != nil && !now.Before(*code.ValidUntil)) {
+		return nil, nil, ErrPromoExpired
+	}
+	if (code.EventID != nil && *code.EventID != event.ID) || (code.Category != "" && code.Category != event.Category) {
+		return nil, nil, ErrPromoNotApplicable
+	}
+	if code.MaxUses != nil && code.UsedCount >= *code.MaxUses {
+		return nil, nil, ErrPromoExhausted
+	}
+	var used int64
+	if err := tx.Model(&models.PromoRedemption{}).Where("promo_code_id = ? AND user_id = ?", code.ID, userID).
+		Count(&used).Error; err != nil {
+		return nil, nil, err
+	}
+	if used > 0 {
+		return nil, nil, ErrPromoAlreadyUsed
+	}
+
+	if err := tx.Model(&code).Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return nil, nil, err
+	}
+	code.UsedCount++
+
+	redemption := models.PromoRedemption{
+		PromoCodeID: code.ID,
+		UserID:      userID,
+		EventID:     event.ID,
+		DiscountKZT: discountFor(&code, amount),
+	}
+	if err := tx.Create(&redemption).Error; err != nil {
+		return nil, nil, err
+	}
+	return &code, &redemption, nil
+}
+
+// releasePromoRedemption gives back the use taken by a payment that failed or
+// was refunded.
+func releasePromoRedemption(tx *gorm.DB, payment *models.Payment) error {
+	if payment.PromoCodeID == nil {
+		return nil
+	}
+	return releaseRedemptions(tx, "payment_id = ?", payment.ID)
+}
+
+// releaseRedemptions deletes the redemptions matching the condition and gives
+// their uses back to the codes. A released code can be redeemed again by the
+// same user.
+func releaseRedemptions(tx *gorm.DB, condition string, args ...interface{}) error {
+	var redemptions []models.PromoRedemption
+	if err := tx.Where(condition, args...).Find(&redemptions).Error; err != nil {
+		return err
+	}
+	for i := range redemptions {
+		if err := tx.Unscoped().Delete(&redemptions[i]).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PromoCode{}).Where("id = ? AND used_count > 0", redemptions[i].PromoCodeID).
+			Update("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePromoError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPromoInvalid), errors.Is(err, ErrPromoExpired),
+		errors.Is(err, ErrPromoNotApplicable), errors.Is(err, ErrPromoExhausted),
+		errors.Is(err, ErrPromoAlreadyUsed):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		writeRegistrationError(w, err)
+	}
+}
+
+func ListPromoCodes(w http.ResponseWriter, r *http.Request) {
+	var codes []models.PromoCode
+	query := db.DB.Order("created_at DESC")
+	if eventID := r.URL.Query().Get("event_id"); eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+	if err := query.Find(&codes).Error; err != nil {
+		http.Error(w, "Failed to fetch promo codes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
+}
+
+func CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	var req PromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	code := models.PromoCode{Active: true}
+	if err := req.apply(&code); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var existing int64
+	db.DB.Unscoped().Model(&models.PromoCode{}).Where("code = ?", code.Code).Count(&existing)
+	if existing > 0 {
+		http.Error(w, "Promo code already exists", http.StatusConflict)
+		return
+	}
+
+	if err := db.DB.Create(&code).Error; err != nil {
+		log.Printf("Failed to create promo code: %v", err)
+		http.Error(w, "Failed to create promo code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(code)
+}
+
+func GetPromoCode(w http.ResponseWriter, r *http.Request) {
+	var code models.PromoCode
+	if err := db.DB.First(&code, mux.Vars(r)["codeId"]).Error; err != nil {
+		http.Error(w, "Promo code not found", http.StatusNotFound)
+		return
+	}
+
+	var redemptions []models.PromoRedemption
+	if err := db.DB.Where("promo_code_id = ?", code.ID).Order("created_at DESC").Find(&redemptions).Error; err != nil {
+		http.Error(w, "Failed to fetch redemptions", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		models.PromoCode
+		Redemptions []models.PromoRedemption `json:"redemptions"`
+	}{
+		PromoCode:   code,
+		Redemptions: redemptions,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdatePromoCode(w http.ResponseWriter, r *http.Request) {
+	var code models.PromoCode
+	if err := db.DB.First(&code, mux.Vars(r)["codeId"]).Error; err != nil {
+		http.Error(w, "Promo code not found", http.StatusNotFound)
+		return
+	}
+
+	var req PromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(&code); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DB.Save(&code).Error; err != nil {
+		http.Error(w, "Failed to update promo code", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(code)
+}
+
+// DeletePromoCode deactivates the code. Redemptions keep pointing at it.
+func DeletePromoCode(w http.ResponseWriter, r *http.Request) {
+	result := db.DB.Model(&models.PromoCode{}).Where("id = ?", mux.Vars(r)["codeId"]).Update("active", false)
+	if result.Error != nil {
+		http.Error(w, "Failed to delete promo code", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Promo code not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/shared/gallery"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+const relatedEventsLimit = 5
+
+// GetPublishedEvent is the public detail view. It returns 404 for unpublished
+// events and includes other upcoming events in the same category.
+func GetPublishedEvent(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var event models.Event
+	if err := db.DB.Preload("PriceTiers").Preload("Images", gallery.Ordered).Where("is_published = ?", true).First(&event, id).Error; err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	response := struct {
+		models.Event
+		Related []models.Event `json:"related"`
+	}{
+		Event:   event,
+		Related: []models.Event{},
+	}
+
+	if event.Category != "" {
+		if err := db.DB.Where("is_published = ? AND category = ? AND id <> ?", true, event.Category, event.ID).
+			Where("(end_date >= ? OR recurrence_rule <> ?)", time.Now(), "").
+			Order("start_date ASC").
+			Limit(relatedEventsLimit).
+			Find(&response.Related).Error; err != nil {
+			http.Error(w, "Failed to fetch related events", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,488 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"log"
+	"net/http"
+)
+
+var (
+	ErrEventNotFound       = errors.New("event not found")
+	ErrAlreadyRegistered   = errors.New("already registered for this event")
+	ErrRegistrationMissing = errors.New("no active registration for this event")
+	ErrWaitlistMismatch    = errors.New("registration_ids must list every waitlisted registration exactly once")
+	ErrEventFull           = errors.New("event is full")
+	ErrPaymentRequired     = errors.New("this event requires payment, use checkout")
+)
+
+func userIDFromContext(r *http.Request) (uint, bool) {
+	userID, ok := r.Context().Value("user_id").(uint)
+	return userID, ok && userID != 0
+}
+
+// lockEvent loads a published, not cancelled event with a row lock so that
+// concurrent registrations see a consistent CurrentCount.
+func lockEvent(tx *gorm.DB, eventID string) (*models.Event, error) {
+	var event models.Event
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("is_published = ? AND cancelled_at IS NULL", true).
+		First(&event, eventID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// registerUser registers the user for a free event, redeeming promoCode if
+// one is given so partner codes are counted against their limits.
+func registerUser(tx *gorm.DB, eventID string, userID uint, promoCode string) (*models.EventRegistration, error) {
+	event, err := lockEvent(tx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	paid, err := hasPriceTiers(tx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	if paid {
+		return nil, ErrPaymentRequired
+	}
+
+	registration, err := seatUser(tx, event, userID, 1, true)
+	if err != nil || promoCode == "" {
+		return registration, err
+	}
+	_, redemption, err := redeemPromoCode(tx, promoCode, event, userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return registration, tx.Model(redemption).Update("registration_id", registration.ID).Error
+}
+
+// hasRoom reports whether seats more seats fit in a locked event without
+// taking them ahead of anyone on its waitlist.
+func hasRoom(tx *gorm.DB, event *models.Event, seats int) (bool, error) {
+	if event.CurrentCount+seats > event.Capacity {
+		return false, nil
+	}
+	var waiting int64
+	err := tx.Model(&models.EventRegistration{}).
+		Where("event_id = ? AND status = ?", event.ID, models.RegistrationStatusWaitlisted).
+		Count(&waiting).Error
+	return waiting == 0, err
+}
+
+// seatUser registers the user for seats seats of a locked event. When they do
+// not fit, or others are already waiting, the user is waitlisted, or
+// ErrEventFull is returned if allowWaitlist is false. Only single seats are
+// waitlisted.
+func seatUser(tx *gorm.DB, event *models.Event, userID uint, seats int, allowWaitlist bool) (*models.EventRegistration, error) {
+	var registration models.EventRegistration
+	err := tx.Where("event_id = ? AND user_id = ?", event.ID, userID).First(&registration).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && registration.Status != models.RegistrationStatusCancelled {
+		return nil, ErrAlreadyRegistered
+	}
+
+	registration.EventID = event.ID
+	registration.UserID = userID
+	registration.Seats = seats
+
+	room, err := hasRoom(tx, event, seats)
+	if err != nil {
+		return nil, err
+	}
+	if !room {
+		if !allowWaitlist || seats != 1 {
+			return nil, ErrEventFull
+		}
+
+		var lastPosition int
+		if err := tx.Model(&models.EventRegistration{}).
+			Where("event_id = ? AND status = ?", event.ID, models.RegistrationStatusWaitlisted).
+			Select("COALESCE(MAX(position), 0)").Scan(&lastPosition).Error; err != nil {
+			return nil, err
+		}
+
+		registration.Status = models.RegistrationStatusWaitlisted
+		registration.Position = lastPosition + 1
+		if err := tx.Save(&registration).Error; err != nil {
+			return nil, err
+		}
+		return &registration, fillWaitlistPosition(tx, &registration)
+	}
+
+	registration.Status = models.RegistrationStatusRegistered
+	registration.Position = 0
+	if err := tx.Save(&registration).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.Event{}).Where("id = ?", event.ID).
+		Update("current_count", gorm.Expr("current_count + ?", seats)).Error; err != nil {
+		return nil, err
+	}
+	if _, err := issueTicket(tx, &registration); err != nil {
+		return nil, err
+	}
+	return &registration, nil
+}
+
+func cancelUser(tx *gorm.DB, eventID string, userID uint) error {
+	event, err := lockEvent(tx, eventID)
+	if err != nil {
+		return err
+	}
+
+	var registration models.EventRegistration
+	err = tx.Where("event_id = ? AND user_id = ? AND status IN ?", event.ID, userID,
+		[]string{models.RegistrationStatusRegistered, models.RegistrationStatusWaitlisted}).
+		First(&registration).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrRegistrationMissing
+	}
+	if err != nil {
+		return err
+	}
+
+	wasRegistered := registration.Status == models.RegistrationStatusRegistered
+	if err := tx.Model(&registration).Updates(map[string]interface{}{
+		"status":   models.RegistrationStatusCancelled,
+		"position": 0,
+	}).Error; err != nil {
+		return err
+	}
+	if err := releaseRedemptions(tx, "registration_id = ?", registration.ID); err != nil {
+		return err
+	}
+	if !wasRegistered {
+		return nil
+	}
+	if err := revokeTicket(tx, &registration); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Event{}).Where("id = ?", event.ID).
+		Update("current_count", gorm.Expr("GREATEST(current_count - ?, 0)", registration.Seats)).Error; err != nil {
+		return err
+	}
+	return promoteWaitlist(tx, event.ID)
+}
+
+// promoteWaitlist moves people from the head of the waitlist into free seats.
+// The caller must hold the event row lock.
+func promoteWaitlist(tx *gorm.DB, eventID uint) error {
+	var event models.Event
+	if err := tx.First(&event, eventID).Error; err != nil {
+		return err
+	}
+
+	free := event.Capacity - event.CurrentCount
+	if free <= 0 {
+		return nil
+	}
+
+	var next []models.EventRegistration
+	if err := tx.Where("event_id = ? AND status = ?", eventID, models.RegistrationStatusWaitlisted).
+		Order("position ASC, created_at ASC").
+		Limit(free).
+		Find(&next).Error; err != nil {
+		return err
+	}
+
+	for i := range next {
+		if err := tx.Model(&next[i]).Updates(map[string]interface{}{
+			"status":   models.RegistrationStatusRegistered,
+			"position": 0,
+		}).Error; err != nil {
+			return err
+		}
+		if _, err := issueTicket(tx, &next[i]); err != nil {
+			return err
+		}
+		log.Printf("Promoted user %d from waitlist for event %d", next[i].UserID, eventID)
+	}
+
+	if len(next) == 0 {
+		return nil
+	}
+	return tx.Model(&models.Event{}).Where("id = ?", eventID).
+		Update("current_count", gorm.Expr("current_count + ?", len(next))).Error
+}
+
+// fillWaitlistPosition sets the 1-based place in line for a waitlisted registration.
+func fillWaitlistPosition(tx *gorm.DB, registration *models.EventRegistration) error {
+	if registration.Status != models.RegistrationStatusWaitlisted {
+		return nil
+	}
+
+	var ahead int64
+	if err := tx.Model(&models.EventRegistration{}).
+		Where("event_id = ? AND status = ? AND (position < ? OR (position = ? AND created_at < ?))",
+			registration.EventID, models.RegistrationStatusWaitlisted,
+			registration.Position, registration.Position, registration.CreatedAt).
+		Count(&ahead).Error; err != nil {
+		return err
+	}
+	registration.WaitlistPosition = int(ahead) + 1
+	return nil
+}
+
+func writeRegistrationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrEventNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyRegistered):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrRegistrationMissing):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrEventFull):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrPaymentRequired):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	default:
+		log.Printf("Registration failed: %v", err)
+		http.Error(w, "Failed to process registration", http.StatusInternalServerError)
+	}
+}
+
+func RegisterForEvent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized - User ID missing", http.StatusUnauthorized)
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	// The body is optional and only carries a promo code.
+	var req struct {
+		PromoCode string `json:"promo_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var registration *models.EventRegistration
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		registration, err = registerUser(tx, id, userID, req.PromoCode)
+		return err
+	})
+	if err != nil {
+		writePromoError(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if registration.Status == models.RegistrationStatusWaitlisted {
+		status = http.StatusAccepted
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(registration)
+}
+
+func GetRegistrationStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized - User ID missing", http.StatusUnauthorized)
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	var registration models.EventRegistration
+	if err := db.DB.Where("event_id = ? AND user_id = ?", id, userID).First(&registration).Error; err != nil {
+		http.Error(w, "Not registered for this event", http.StatusNotFound)
+		return
+	}
+
+	if err := fillWaitlistPosition(db.DB, &registration); err != nil {
+		http.Error(w, "Failed to fetch registration", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(registration)
+}
+
+func CancelRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized - User ID missing", http.StatusUnauthorized)
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return cancelUser(tx, id, userID)
+	})
+	if err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ListMyRegistrations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized - User ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	var registrations []models.EventRegistration
+	query := db.DB.Preload("Event").Where("user_id = ?", userID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	} else {
+		query = query.Where("status <> ?", models.RegistrationStatusCancelled)
+	}
+
+	if err := query.Order("created_at DESC").Find(&registrations).Error; err != nil {
+		http.Error(w, "Failed to fetch registrations", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range registrations {
+		if err := fillWaitlistPosition(db.DB, &registrations[i]); err != nil {
+			http.Error(w, "Failed to fetch registrations", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(registrations)
+}
+
+func ListEventAttendees(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var event models.Event
+	if err := db.DB.First(&event, id).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+
+	var attendees []models.EventRegistration
+	if err := db.DB.Where("event_id = ? AND status = ?", event.ID, models.RegistrationStatusRegistered).
+		Order("created_at ASC").
+		Find(&attendees).Error; err != nil {
+		http.Error(w, "Failed to fetch attendees", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		EventID      uint                       `json:"event_id"`
+		Capacity     int                        `json:"capacity"`
+		CurrentCount int                        `json:"current_count"`
+		Attendees    []models.EventRegistration `json:"attendees"`
+	}{
+		EventID:      event.ID,
+		Capacity:     event.Capacity,
+		CurrentCount: event.CurrentCount,
+		Attendees:    attendees,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func ListEventWaitlist(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var event models.Event
+	if err := db.DB.First(&event, id).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+
+	var waitlist []models.EventRegistration
+	if err := db.DB.Where("event_id = ? AND status = ?", event.ID, models.RegistrationStatusWaitlisted).
+		Order("position ASC, created_at ASC").
+		Find(&waitlist).Error; err != nil {
+		http.Error(w, "Failed to fetch waitlist", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range waitlist {
+		waitlist[i].WaitlistPosition = i + 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(waitlist)
+}
+
+// ReorderEventWaitlist takes the complete waitlist as an ordered list of
+// registration IDs and renumbers positions to match.
+func ReorderEventWaitlist(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req struct {
+		RegistrationIDs []uint `json:"registration_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+			return ErrEventNotFound
+		}
+
+		var waitlist []models.EventRegistration
+		if err := tx.Where("event_id = ? AND status = ?", event.ID, models.RegistrationStatusWaitlisted).
+			Find(&waitlist).Error; err != nil {
+			return err
+		}
+
+		current := make(map[uint]bool, len(waitlist))
+		for _, registration := range waitlist {
+			current[registration.ID] = true
+		}
+		if len(req.RegistrationIDs) != len(current) {
+			return ErrWaitlistMismatch
+		}
+		for _, registrationID := range req.RegistrationIDs {
+			if !current[registrationID] {
+				return ErrWaitlistMismatch
+			}
+			delete(current, registrationID)
+		}
+
+		for i, registrationID := range req.RegistrationIDs {
+			if err := tx.Model(&models.EventRegistration{}).Where("id = ?", registrationID).
+				Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrWaitlistMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	ListEventWaitlist(w, r)
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/audit"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+var ErrEventCancelled = errors.New("cancelled events cannot be published")
+
+// ScheduleRequest sets both scheduled times at once; null or a missing field
+// clears that schedule.
+type ScheduleRequest struct {
+	PublishAt   *string `json:"publish_at"`
+	UnpublishAt *string `json:"unpublish_at"`
+}
+
+// ScheduleEvent sets when the scheduler publishes and unpublishes the event.
+func ScheduleEvent(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	publishAt, err := parseOptionalTime(req.PublishAt)
+	if err != nil {
+		http.Error(w, "Invalid publish_at (expected RFC3339)", http.StatusBadRequest)
+		return
+	}
+	unpublishAt, err := parseOptionalTime(req.UnpublishAt)
+	if err != nil {
+		http.Error(w, "Invalid unpublish_at (expected RFC3339)", http.StatusBadRequest)
+		return
+	}
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		http.Error(w, "unpublish_at must be after publish_at", http.StatusBadRequest)
+		return
+	}
+
+	var event *models.Event
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		event, err = lockEventForAdmin(tx, id)
+		if err != nil {
+			return err
+		}
+		if event.CancelledAt != nil && publishAt != nil {
+			return ErrEventCancelled
+		}
+		before, err := audit.Snapshot(event)
+		if err != nil {
+			return err
+		}
+
+		event.PublishAt = publishAt
+		event.UnpublishAt = unpublishAt
+		if err := tx.Model(event).Updates(map[string]interface{}{
+			"publish_at":   publishAt,
+			"unpublish_at": unpublishAt,
+		}).Error; err != nil {
+			return err
+		}
+		return models.EventAudit.Record(tx, access.AdminID(r), audit.ActionSchedule, event.ID, before, event)
+	})
+	switch {
+	case errors.Is(err, ErrEventNotFound):
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrEventCancelled):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to schedule event", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
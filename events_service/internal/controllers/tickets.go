@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/events_service/utils/tickets"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// issueTicket creates the ticket for a confirmed registration if it does not
+// have one yet.
+func issueTicket(tx *gorm.DB, registration *models.EventRegistration) (*models.Ticket, error) {
+	var ticket models.Ticket
+	err := tx.Where("registration_id = ?", registration.ID).First(&ticket).Error
+	if err == nil {
+		return &ticket, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	code, err := tickets.NewCode(registration.EventID, registration.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket = models.Ticket{
+		EventID:        registration.EventID,
+		UserID:         registration.UserID,
+		RegistrationID: registration.ID,
+		Code:           code,
+		Seats:          registration.Seats,
+	}
+	if err := tx.Create(&ticket).Error; err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+func revokeTicket(tx *gorm.DB, registration *models.EventRegistration) error {
+	return tx.Unscoped().Where("registration_id = ?", registration.ID).Delete(&models.Ticket{}).Error
+}
+
+// findMyTicket returns the caller's ticket for the event, issuing it on the
+// fly for registrations made before tickets existed.
+func findMyTicket(r *http.Request) (*models.Ticket, int, error) {
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		return nil, http.StatusUnauthorized, errors.New("Unauthorized - User ID missing")
+	}
+	id := mux.Vars(r)["id"]
+
+	var registration models.EventRegistration
+	if err := db.DB.Where("event_id = ? AND user_id = ? AND status = ?",
+		id, userID, models.RegistrationStatusRegistered).First(&registration).Error; err != nil {
+		return nil, http.StatusNotFound, errors.New("No confirmed registration for this event")
+	}
+
+	ticket, err := issueTicket(db.DB, &registration)
+	if err != nil {
+		log.Printf("Failed to issue ticket: %v", err)
+		return nil, http.StatusInternalServerError, errors.New("Failed to issue ticket")
+	}
+	return ticket, http.StatusOK, nil
+}
+
+func GetMyTicket(w http.ResponseWriter, r *http.Request) {
+	ticket, status, err := findMyTicket(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ticket)
+}
+
+func GetMyTicketQR(w http.ResponseWriter, r *http.Request) {
+	ticket, status, err := findMyTicket(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	png, err := qrcode.Encode(ticket.Code, qrcode.Medium, 512)
+	if err != nil {
+		log.Printf("Failed to render QR code: %v", err)
+		http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", "inline; filename=\"ticket-"+strconv.FormatUint(uint64(ticket.ID), 10)+".png\"")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(png)
+}
+
+// GetEventTicketKey returns the hex-encoded key that door scanners use to
+// verify ticket codes for this event while offline.
+func GetEventTicketKey(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var event models.Event
+	if err := db.DB.First(&event, id).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"event_id":  event.ID,
+		"algorithm": "HMAC-SHA256",
+		"key":       hex.EncodeToString(tickets.EventKey(event.ID)),
+	})
+}
+
+func CheckInTicket(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	adminID, _ := r.Context().Value("admin_id").(uint)
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var event models.Event
+	if err := db.DB.First(&event, id).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+
+	if _, err := tickets.Verify(req.Code, event.ID, tickets.EventKey(event.ID)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ticket models.Ticket
+	if err := db.DB.Where("code = ? AND event_id = ?", req.Code, event.ID).First(&ticket).Error; err != nil {
+		http.Error(w, "Ticket has been revoked", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	result := db.DB.Model(&models.Ticket{}).
+		Where("id = ? AND checked_in_at IS NULL", ticket.ID).
+		Updates(map[string]interface{}{"checked_in_at": now, "checked_in_by": adminID})
+	if result.Error != nil {
+		http.Error(w, "Failed to check in ticket", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		db.DB.First(&ticket, ticket.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":         "ticket already used",
+			"checked_in_at": ticket.CheckedInAt,
+		})
+		return
+	}
+
+	ticket.CheckedInAt = &now
+	ticket.CheckedInBy = &adminID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ticket)
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/shared/access"
+	"diplomaPorject/backend/shared/update"
+	"errors"
+	"log"
+	"net/http"
+)
+
+func writeUpdateError(w http.ResponseWriter, err error) {
+	var invalid *update.InvalidError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrEventNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, access.ErrNotOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, update.ErrPreconditionFailed):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, ErrCapacityBelowCount):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Failed to update event: %v", err)
+		http.Error(w, "Failed to update event", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+type SessionResponse struct {
+	UserID        uint `json:"user_id"`
+	EmailVerified bool `json:"email_verified"`
+}
+
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("User authentication for: %s", r.URL.Path)
+
+		authServiceURL := "http://auth-service:8082/validate-session"
+
+		req, err := http.NewRequest("GET", authServiceURL, nil)
+		if err != nil {
+			log.Printf("Error creating session validation request: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		req.Header.Set("Cookie", r.Header.Get("Cookie"))
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Printf("Error calling auth service for session validation: %v", err)
+			http.Error(w, "Unauthorized - Auth service error", http.StatusUnauthorized)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			log.Printf("Session validation failed. Status: %d", resp.StatusCode)
+			http.Error(w, "Unauthorized - Invalid session", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			log.Printf("Error reading response body: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		var sessionResp SessionResponse
+		if err := json.Unmarshal(body, &sessionResp); err != nil {
+			log.Printf("Error parsing session response: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "user_id", sessionResp.UserID)
+		ctx = context.WithValue(ctx, "email_verified", sessionResp.EmailVerified)
+
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// VerifiedOnly guards a handler behind AuthMiddleware that is closed to
+// users who have not confirmed their email address yet.
+func VerifiedOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if verified, _ := r.Context().Value("email_verified").(bool); !verified {
+			http.Error(w, "Please verify your email address first", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
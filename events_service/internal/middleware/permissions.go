@@ -0,0 +1,10 @@
+package middleware
+
+// Event permissions granted by the auth service's roles. Permissions every
+// service checks are in the shared access package.
+const (
+	PermEventsView      = "events.view"
+	PermEventsEdit      = "events.edit"
+	PermEventsPublish   = "events.publish"
+	PermEventsAttendees = "events.attendees"
+)
@@ -0,0 +1,20 @@
+package models
+
+import "diplomaPorject/backend/shared/audit"
+
+// AuditEntry keeps the audit log of events in its own table.
+type AuditEntry struct {
+	audit.Entry
+}
+
+func (AuditEntry) TableName() string {
+	return "event_audit_entries"
+}
+
+// EventAudit records admin changes to events. Price tiers are records of
+// their own and are left out of event diffs.
+var EventAudit = audit.Log{
+	Table:      AuditEntry{}.TableName(),
+	EntityType: "event",
+	Ignore:     []string{"price_tiers"},
+}
@@ -7,15 +7,27 @@ import (
 
 type Event struct {
 	gorm.Model
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	StartDate    time.Time `json:"start_date"`
-	EndDate      time.Time `json:"end_date"`
-	Location     string    `json:"location"`
-	Capacity     int       `json:"capacity"`
-	IsPublished  bool      `json:"is_published" gorm:"default:false"`
-	AdminID      uint      `json:"admin_id"`
-	CurrentCount int       `json:"current_count" gorm:"default:0"`
-	ImageURL     string    `json:"image_url"`
-	Category     string    `json:"category"`
+	Title           string        `json:"title"`
+	Description     string        `json:"description"`
+	StartDate       time.Time     `json:"start_date"`
+	EndDate         time.Time     `json:"end_date"`
+	Location        string        `json:"location"`
+	Capacity        int           `json:"capacity"`
+	IsPublished     bool          `json:"is_published" gorm:"default:false"`
+	AdminID         uint          `json:"admin_id"`
+	CurrentCount    int           `json:"current_count" gorm:"default:0"`
+	ImageURL        string        `json:"image_url"`
+	ImageVariants   ImageVariants `json:"image_variants,omitempty" gorm:"type:jsonb"`
+	Category        string        `json:"category"`
+	Sequence        int           `json:"-" gorm:"default:0"`
+	RecurrenceRule  string        `json:"recurrence_rule,omitempty" gorm:"not null;default:''"`
+	OccurrenceStart *time.Time    `json:"occurrence_start,omitempty" gorm:"-"`
+	Latitude        *float64      `json:"latitude" gorm:"index:idx_event_coordinates"`
+	Longitude       *float64      `json:"longitude" gorm:"index:idx_event_coordinates"`
+	CancelledAt     *time.Time    `json:"cancelled_at,omitempty"`
+	PublishAt       *time.Time    `json:"publish_at" gorm:"index"`
+	UnpublishAt     *time.Time    `json:"unpublish_at" gorm:"index"`
+	ArchivedAt      *time.Time    `json:"archived_at,omitempty"`
+	PriceTiers      []PriceTier   `json:"price_tiers,omitempty"`
+	Images          []EventImage  `json:"images,omitempty"`
 }
@@ -0,0 +1,20 @@
+package models
+
+import "diplomaPorject/backend/shared/gallery"
+
+// EventImage is one picture in an event's gallery.
+type EventImage struct {
+	gallery.Image
+	EventID uint `json:"event_id" gorm:"not null;index"`
+}
+
+// GalleryID and SetCoverImage make an event a gallery.Owner; the cover is
+// mirrored into ImageURL and ImageVariants.
+func (e *Event) GalleryID() uint {
+	return e.ID
+}
+
+func (e *Event) SetCoverImage(url string, variants ImageVariants) {
+	e.ImageURL = url
+	e.ImageVariants = variants
+}
@@ -0,0 +1,10 @@
+package models
+
+import "diplomaPorject/backend/shared/media"
+
+// Event images and galleries store the variant types of the shared media
+// package.
+type (
+	ImageVariant  = media.ImageVariant
+	ImageVariants = media.ImageVariants
+)
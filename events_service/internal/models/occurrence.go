@@ -0,0 +1,20 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// EventOccurrenceOverride cancels or changes one occurrence of a recurring
+// event, identified by the start time the recurrence rule generated for it.
+type EventOccurrenceOverride struct {
+	gorm.Model
+	EventID         uint       `json:"event_id" gorm:"not null;uniqueIndex:idx_event_occurrence"`
+	OccurrenceStart time.Time  `json:"occurrence_start" gorm:"not null;uniqueIndex:idx_event_occurrence"`
+	Cancelled       bool       `json:"cancelled" gorm:"default:false"`
+	StartDate       *time.Time `json:"start_date,omitempty"`
+	EndDate         *time.Time `json:"end_date,omitempty"`
+	Title           *string    `json:"title,omitempty"`
+	Description     *string    `json:"description,omitempty"`
+	Location        *string    `json:"location,omitempty"`
+}
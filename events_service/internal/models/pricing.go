@@ -0,0 +1,58 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	PaymentStatusPending = "pending"
+	PaymentStatusPaid    = "paid"
+	PaymentStatusFailed  = "failed"
+	// PaymentStatusRefunding claims a paid payment while its refund is with
+	// the provider, so that it is refunded only once.
+	PaymentStatusRefunding = "refunding"
+	PaymentStatusRefunded  = "refunded"
+)
+
+// PriceTier is a ticket price for an event. Amounts are in minor units:
+// tiyn for KZT, cents for the optional USD/EUR display prices. Only the KZT
+// amount is charged.
+type PriceTier struct {
+	gorm.Model
+	EventID    uint   `json:"event_id" gorm:"not null;index"`
+	Name       string `json:"name" gorm:"not null"`
+	AmountKZT  int64  `json:"amount_kzt" gorm:"not null"`
+	DisplayUSD *int64 `json:"display_usd,omitempty"`
+	DisplayEUR *int64 `json:"display_eur,omitempty"`
+}
+
+// GroupDiscount takes Percent off the ticket price when one checkout buys at
+// least MinSeats seats. The largest discount a group qualifies for applies.
+type GroupDiscount struct {
+	gorm.Model
+	EventID  uint  `json:"event_id" gorm:"not null;index"`
+	MinSeats int   `json:"min_seats" gorm:"not null"`
+	Percent  int64 `json:"percent" gorm:"not null"`
+}
+
+// Payment pays for Seats seats of one price tier. AmountKZT is what is
+// charged, after the group discount and then the promo code discount.
+type Payment struct {
+	gorm.Model
+	EventID          uint       `json:"event_id" gorm:"not null;index"`
+	UserID           uint       `json:"user_id" gorm:"not null;index"`
+	PriceTierID      uint       `json:"price_tier_id"`
+	Seats            int        `json:"seats" gorm:"not null;default:1"`
+	AmountKZT        int64      `json:"amount_kzt"`
+	GroupDiscountKZT int64      `json:"group_discount_kzt"`
+	DiscountKZT      int64      `json:"discount_kzt"`
+	PromoCodeID      *uint      `json:"promo_code_id,omitempty"`
+	Currency         string     `json:"currency" gorm:"not null;default:KZT"`
+	Provider         string     `json:"provider" gorm:"not null;uniqueIndex:idx_payment_provider_ref"`
+	ProviderRef      *string    `json:"provider_ref" gorm:"uniqueIndex:idx_payment_provider_ref"`
+	Status           string     `json:"status" gorm:"not null;default:pending;index"`
+	RegistrationID   *uint      `json:"registration_id,omitempty"`
+	PaidAt           *time.Time `json:"paid_at,omitempty"`
+	RefundedAt       *time.Time `json:"refunded_at,omitempty"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	DiscountTypePercent = "percent"
+	DiscountTypeFixed   = "fixed"
+)
+
+// PromoCode discounts event tickets. DiscountValue is a percentage (1-100) for
+// percent codes and an amount in tiyn for fixed codes. A code may be limited
+// to one event, one category, a validity window and a number of uses; each
+// user can redeem it once.
+type PromoCode struct {
+	gorm.Model
+	Code          string     `json:"code" gorm:"not null;uniqueIndex"`
+	DiscountType  string     `json:"discount_type" gorm:"not null"`
+	DiscountValue int64      `json:"discount_value" gorm:"not null"`
+	MaxUses       *int       `json:"max_uses"`
+	UsedCount     int        `json:"used_count" gorm:"not null;default:0"`
+	ValidFrom     *time.Time `json:"valid_from"`
+	ValidUntil    *time.Time `json:"valid_until"`
+	EventID       *uint      `json:"event_id" gorm:"index"`
+	Category      string     `json:"category"`
+	Active        bool       `json:"active" gorm:"not null"`
+}
+
+type PromoRedemption struct {
+	gorm.Model
+	PromoCodeID    uint  `json:"promo_code_id" gorm:"not null;index"`
+	UserID         uint  `json:"user_id" gorm:"not null;index"`
+	EventID        uint  `json:"event_id" gorm:"not null"`
+	PaymentID      *uint `json:"payment_id,omitempty" gorm:"index"`
+	RegistrationID *uint `json:"registration_id,omitempty"`
+	DiscountKZT    int64 `json:"discount_kzt"`
+}
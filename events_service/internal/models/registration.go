@@ -0,0 +1,25 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	RegistrationStatusRegistered = "registered"
+	RegistrationStatusWaitlisted = "waitlisted"
+	RegistrationStatusCancelled  = "cancelled"
+)
+
+// EventRegistration holds Seats seats for the user. Group bookings bought in
+// one checkout take several seats; free registrations always take one.
+type EventRegistration struct {
+	gorm.Model
+	EventID uint   `json:"event_id" gorm:"not null;uniqueIndex:idx_event_user"`
+	UserID  uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_event_user;index"`
+	Status  string `json:"status" gorm:"not null;default:registered;index"`
+	Seats   int    `json:"seats" gorm:"not null;default:1"`
+	// Position orders the waitlist; it is only meaningful while Status is waitlisted.
+	Position         int    `json:"-" gorm:"default:0"`
+	WaitlistPosition int    `json:"waitlist_position,omitempty" gorm:"-"`
+	Event            *Event `json:"event,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
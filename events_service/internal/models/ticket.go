@@ -0,0 +1,18 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+type Ticket struct {
+	gorm.Model
+	EventID        uint   `json:"event_id" gorm:"not null;index"`
+	UserID         uint   `json:"user_id" gorm:"not null;index"`
+	RegistrationID uint   `json:"registration_id" gorm:"not null;uniqueIndex"`
+	Code           string `json:"code" gorm:"not null;uniqueIndex"`
+	// Seats is how many people the ticket admits.
+	Seats       int        `json:"seats" gorm:"not null;default:1"`
+	CheckedInAt *time.Time `json:"checked_in_at"`
+	CheckedInBy *uint      `json:"checked_in_by,omitempty"`
+}
@@ -0,0 +1,100 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	FakeProviderName    = "fake"
+	fakeSignatureHeader = "X-Fake-Signature"
+)
+
+// FakeProvider accepts every checkout without moving money. It is meant for
+// development and is only used with PAYMENT_PROVIDER=fake. Its webhooks are
+// signed with PAYMENT_WEBHOOK_SECRET so the webhook path is exercised the
+// same way as with a real provider. The secret is required: with a known one
+// anyone could mark payments as paid.
+type FakeProvider struct {
+	secret  []byte
+	baseURL string
+}
+
+type fakeWebhookPayload struct {
+	ProviderRef string `json:"provider_ref"`
+	Status      string `json:"status"`
+}
+
+func NewFakeProvider() (*FakeProvider, error) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, errors.New("PAYMENT_WEBHOOK_SECRET is not set")
+	}
+	return &FakeProvider{
+		secret:  []byte(secret),
+		baseURL: strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+	}, nil
+}
+
+func (p *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakeProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error) {
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	ref := "fake_" + hex.EncodeToString(nonce)
+	return &CheckoutSession{
+		ProviderRef: ref,
+		RedirectURL: fmt.Sprintf("%s/payments/fake/%s/pay", p.baseURL, ref),
+	}, nil
+}
+
+func (p *FakeProvider) signature(body []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Sign returns the signature header value for a webhook body.
+func (p *FakeProvider) Sign(body []byte) string {
+	return hex.EncodeToString(p.signature(body))
+}
+
+func (p *FakeProvider) ParseWebhook(r *http.Request) (*WebhookEvent, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		return nil, ErrMalformedWebhook
+	}
+	signature, err := hex.DecodeString(r.Header.Get(fakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, p.signature(body)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload fakeWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.ProviderRef == "" {
+		return nil, ErrMalformedWebhook
+	}
+	if payload.Status != StatusPaid && payload.Status != StatusFailed {
+		return nil, ErrMalformedWebhook
+	}
+	return &WebhookEvent{ProviderRef: payload.ProviderRef, Status: payload.Status}, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, providerRef string, amountKZT int64) error {
+	log.Printf("Fake provider refunded %d tiyn for payment %s", amountKZT, providerRef)
+	return nil
+}
@@ -0,0 +1,81 @@
+// Package payments abstracts the payment provider used for paid event
+// checkouts. The provider is chosen with PAYMENT_PROVIDER; only the local
+// "fake" provider ships with the service.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+const (
+	StatusPaid   = "paid"
+	StatusFailed = "failed"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrMalformedWebhook = errors.New("malformed webhook payload")
+)
+
+type CheckoutRequest struct {
+	PaymentID   uint
+	EventID     uint
+	UserID      uint
+	AmountKZT   int64
+	Description string
+}
+
+// CheckoutSession is where the user is sent to pay. ProviderRef identifies the
+// payment in later webhooks and refunds.
+type CheckoutSession struct {
+	ProviderRef string
+	RedirectURL string
+}
+
+// WebhookEvent is a verified payment result reported by the provider.
+type WebhookEvent struct {
+	ProviderRef string
+	Status      string
+}
+
+type PaymentProvider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error)
+	// ParseWebhook verifies the request came from the provider and decodes it.
+	ParseWebhook(r *http.Request) (*WebhookEvent, error)
+	Refund(ctx context.Context, providerRef string, amountKZT int64) error
+}
+
+var active PaymentProvider
+
+// Setup selects the provider named by PAYMENT_PROVIDER. There is no
+// default: the fake provider marks payments as paid without charging, so it
+// has to be asked for by name.
+func Setup() error {
+	name := os.Getenv("PAYMENT_PROVIDER")
+	switch name {
+	case "":
+		return errors.New("PAYMENT_PROVIDER is not set")
+	case FakeProviderName:
+		provider, err := NewFakeProvider()
+		if err != nil {
+			return err
+		}
+		active = provider
+	default:
+		return fmt.Errorf("unknown payment provider %q", name)
+	}
+	return nil
+}
+
+// Active returns the provider chosen by Setup.
+func Active() PaymentProvider {
+	if active == nil {
+		panic("payments: Active called before Setup")
+	}
+	return active
+}
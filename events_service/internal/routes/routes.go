@@ -3,35 +3,92 @@ package routes
 import (
 	"diplomaPorject/backend/events_service/internal/controllers"
 	"diplomaPorject/backend/events_service/internal/middleware"
+	"diplomaPorject/backend/shared/access"
 	"github.com/gorilla/mux"
-	"log"
-	"net/http"
 )
 
 func SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
-	uploadDir := "/app/uploads/events" // Match the upload path
 
-	log.Printf("Serving static files from: %s", uploadDir)
+	// Each admin route checks the permission it needs; AdminAuthMiddleware
+	// only establishes that the caller is an admin.
+	can := access.RequirePermission
 
-	// ✅ Serve event images under /uploads/events
-	r.PathPrefix("/uploads/events/").Handler(
-		http.StripPrefix("/uploads/events/", http.FileServer(http.Dir(uploadDir))),
-	).Methods("GET")
-
-	// Admin routes
+	// Admin routes. Changes to an event go through OwnerOnly, which applies
+	// the edit policy.
 	admin := r.PathPrefix("/admin/events").Subrouter()
-	admin.Use(middleware.AdminAuthMiddleware)
-	admin.HandleFunc("", controllers.CreateEvent).Methods("POST")
-	admin.HandleFunc("", controllers.ListEvents).Methods("GET")
-	admin.HandleFunc("/{id}", controllers.GetEvent).Methods("GET")
-	admin.HandleFunc("/{id}", controllers.UpdateEvent).Methods("PUT")
-	admin.HandleFunc("/{id}", controllers.DeleteEvent).Methods("DELETE")
-	admin.HandleFunc("/{id}/publish", controllers.PublishEvent).Methods("POST")
-	admin.HandleFunc("/{id}/unpublish", controllers.UnpublishEvent).Methods("POST")
+	admin.Use(access.AdminAuthMiddleware)
+	// Registered before "/{id}" so that these paths are not taken for an event ID.
+	admin.HandleFunc("/import", can(middleware.PermEventsEdit, controllers.ImportEvents)).Methods("POST")
+	admin.HandleFunc("/export", can(middleware.PermEventsView, controllers.ExportEvents)).Methods("GET")
+	admin.HandleFunc("/promo-codes", can(middleware.PermEventsView, controllers.ListPromoCodes)).Methods("GET")
+	admin.HandleFunc("/promo-codes", can(middleware.PermEventsEdit, controllers.CreatePromoCode)).Methods("POST")
+	admin.HandleFunc("/promo-codes/{codeId}", can(middleware.PermEventsView, controllers.GetPromoCode)).Methods("GET")
+	admin.HandleFunc("/promo-codes/{codeId}", can(middleware.PermEventsEdit, controllers.UpdatePromoCode)).Methods("PUT")
+	admin.HandleFunc("/promo-codes/{codeId}", can(middleware.PermEventsEdit, controllers.DeletePromoCode)).Methods("DELETE")
+	admin.HandleFunc("", can(middleware.PermEventsEdit, controllers.CreateEvent)).Methods("POST")
+	admin.HandleFunc("", can(middleware.PermEventsView, controllers.ListEvents)).Methods("GET")
+	admin.HandleFunc("/{id}", can(middleware.PermEventsView, controllers.GetEvent)).Methods("GET")
+	admin.HandleFunc("/{id}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.UpdateEvent))).Methods("PUT")
+	admin.HandleFunc("/{id}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.PatchEvent))).Methods("PATCH")
+	admin.HandleFunc("/{id}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.DeleteEvent))).Methods("DELETE")
+	admin.HandleFunc("/{id}/publish", can(middleware.PermEventsPublish, controllers.OwnerOnly(controllers.PublishEvent))).Methods("POST")
+	admin.HandleFunc("/{id}/unpublish", can(middleware.PermEventsPublish, controllers.OwnerOnly(controllers.UnpublishEvent))).Methods("POST")
+	admin.HandleFunc("/{id}/schedule", can(middleware.PermEventsPublish, controllers.OwnerOnly(controllers.ScheduleEvent))).Methods("PUT")
+	admin.HandleFunc("/{id}/images", can(middleware.PermEventsView, controllers.EventImages.List)).Methods("GET")
+	admin.HandleFunc("/{id}/images", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.EventImages.Add))).Methods("POST")
+	admin.HandleFunc("/{id}/images/order", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.EventImages.Reorder))).Methods("PUT")
+	admin.HandleFunc("/{id}/images/{imageId:[0-9]+}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.EventImages.UpdateImage))).Methods("PUT")
+	admin.HandleFunc("/{id}/images/{imageId:[0-9]+}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.EventImages.DeleteImage))).Methods("DELETE")
+	admin.HandleFunc("/{id}/images/{imageId:[0-9]+}/cover", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.EventImages.SetCover))).Methods("POST")
+	admin.HandleFunc("/{id}/cancel", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.CancelEvent))).Methods("POST")
+	admin.HandleFunc("/{id}/prices", can(middleware.PermEventsView, controllers.ListPriceTiers)).Methods("GET")
+	admin.HandleFunc("/{id}/prices", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.CreatePriceTier))).Methods("POST")
+	admin.HandleFunc("/{id}/prices/{tierId}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.UpdatePriceTier))).Methods("PUT")
+	admin.HandleFunc("/{id}/prices/{tierId}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.DeletePriceTier))).Methods("DELETE")
+	admin.HandleFunc("/{id}/group-discounts", can(middleware.PermEventsView, controllers.ListGroupDiscounts)).Methods("GET")
+	admin.HandleFunc("/{id}/group-discounts", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.SaveGroupDiscount))).Methods("PUT")
+	admin.HandleFunc("/{id}/group-discounts/{discountId}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.DeleteGroupDiscount))).Methods("DELETE")
+	admin.HandleFunc("/{id}/attendees", can(middleware.PermEventsAttendees, controllers.ListEventAttendees)).Methods("GET")
+	admin.HandleFunc("/{id}/waitlist", can(middleware.PermEventsAttendees, controllers.ListEventWaitlist)).Methods("GET")
+	admin.HandleFunc("/{id}/waitlist", can(middleware.PermEventsAttendees, controllers.OwnerOnly(controllers.ReorderEventWaitlist))).Methods("PUT")
+	admin.HandleFunc("/{id}/ticket-key", can(middleware.PermEventsAttendees, controllers.GetEventTicketKey)).Methods("GET")
+	admin.HandleFunc("/{id}/check-in", can(middleware.PermEventsAttendees, controllers.CheckInTicket)).Methods("POST")
+	admin.HandleFunc("/{id}/occurrences", can(middleware.PermEventsView, controllers.ListEventOccurrences)).Methods("GET")
+	admin.HandleFunc("/{id}/occurrences", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.SaveOccurrenceOverride))).Methods("PUT")
+	admin.HandleFunc("/{id}/occurrences/{overrideId}", can(middleware.PermEventsEdit, controllers.OwnerOnly(controllers.DeleteOccurrenceOverride))).Methods("DELETE")
+
+	audit := r.PathPrefix("/admin/audit/events").Subrouter()
+	audit.Use(access.AdminAuthMiddleware)
+	audit.HandleFunc("", can(access.PermAuditRead, controllers.ListAuditEntries)).Methods("GET")
 
 	// Public events
 	r.HandleFunc("/events", controllers.ListPublishedEvents).Methods("GET")
+	r.HandleFunc("/events/nearby", controllers.ListNearbyEvents).Methods("GET")
+	r.HandleFunc("/events/calendar.ics", controllers.GetEventsCalendarFeed).Methods("GET")
+	r.HandleFunc("/events/{id:[0-9]+}.ics", controllers.GetEventICS).Methods("GET")
+	r.HandleFunc("/events/{id:[0-9]+}", controllers.GetPublishedEvent).Methods("GET")
+
+	// Payment provider callbacks
+	r.HandleFunc("/payments/webhook/{provider}", controllers.PaymentWebhook).Methods("POST")
+
+	// Registrations for authenticated users. Signing up and paying need a
+	// verified email address.
+	user := r.PathPrefix("/events").Subrouter()
+	user.Use(middleware.AuthMiddleware)
+	user.HandleFunc("/registrations", controllers.ListMyRegistrations).Methods("GET")
+	user.HandleFunc("/{id}/register", controllers.GetRegistrationStatus).Methods("GET")
+	user.HandleFunc("/{id}/register", middleware.VerifiedOnly(controllers.RegisterForEvent)).Methods("POST")
+	user.HandleFunc("/{id}/register", controllers.CancelRegistration).Methods("DELETE")
+	user.HandleFunc("/{id}/checkout", middleware.VerifiedOnly(controllers.Checkout)).Methods("POST")
+	user.HandleFunc("/{id}/ticket", controllers.GetMyTicket).Methods("GET")
+	user.HandleFunc("/{id}/ticket.png", controllers.GetMyTicketQR).Methods("GET")
+
+	// The fake provider's payment page, for development with
+	// PAYMENT_PROVIDER=fake. Only the user who checked out may pay.
+	fakePay := r.PathPrefix("/payments/fake").Subrouter()
+	fakePay.Use(middleware.AuthMiddleware)
+	fakePay.HandleFunc("/{ref}/pay", controllers.CompleteFakePayment).Methods("POST")
 
 	return r
 }
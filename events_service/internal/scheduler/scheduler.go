@@ -0,0 +1,172 @@
+// Package scheduler applies scheduled publish and unpublish times and
+// archives events once they have ended.
+package scheduler
+
+import (
+	"context"
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/events_service/utils/recurrence"
+	"diplomaPorject/backend/shared/audit"
+	"gorm.io/gorm"
+	"log"
+	"os"
+	"time"
+)
+
+// lockKey is the Postgres advisory lock held while a run is in progress, so
+// that only one replica applies schedules at a time. The services share a
+// database, so each uses its own key.
+const lockKey int64 = 0x54524b5a0001
+
+const defaultInterval = time.Minute
+
+func interval() time.Duration {
+	value := os.Getenv("SCHEDULER_INTERVAL")
+	if value == "" {
+		return defaultInterval
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid SCHEDULER_INTERVAL %q, using %s", value, defaultInterval)
+		return defaultInterval
+	}
+	return d
+}
+
+// Start runs the scheduler in the background until ctx is cancelled.
+func Start(ctx context.Context) {
+	every := interval()
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			if err := RunOnce(time.Now()); err != nil {
+				log.Printf("Scheduler run failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Scheduler running every %s", every)
+}
+
+// RunOnce applies everything due at now. It does nothing if another replica
+// holds the lock; the lock is released when the transaction ends.
+func RunOnce(now time.Time) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", lockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		if err := applySchedules(tx, now); err != nil {
+			return err
+		}
+		return archiveEnded(tx, now)
+	})
+}
+
+func applySchedules(tx *gorm.DB, now time.Time) error {
+	var events []models.Event
+	// Archived events stay unpublished whatever was scheduled for them.
+	if err := tx.Where("archived_at IS NULL AND (publish_at <= ? OR unpublish_at <= ?)", now, now).
+		Find(&events).Error; err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		publishDue := event.PublishAt != nil && !event.PublishAt.After(now)
+		unpublishDue := event.UnpublishAt != nil && !event.UnpublishAt.After(now)
+
+		// When both came due since the last run, the later one wins.
+		published := event.IsPublished
+		switch {
+		case publishDue && unpublishDue:
+			published = event.PublishAt.After(*event.UnpublishAt)
+		case publishDue:
+			published = true
+		case unpublishDue:
+			published = false
+		}
+		if event.CancelledAt != nil {
+			published = false
+		}
+
+		before, err := audit.Snapshot(&event)
+		if err != nil {
+			return err
+		}
+
+		event.IsPublished = published
+		updates := map[string]interface{}{"is_published": published}
+		if publishDue {
+			event.PublishAt = nil
+			updates["publish_at"] = nil
+		}
+		if unpublishDue {
+			event.UnpublishAt = nil
+			updates["unpublish_at"] = nil
+		}
+		if err := tx.Model(&event).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		action := audit.ActionUnpublish
+		if published {
+			action = audit.ActionPublish
+		}
+		if err := models.EventAudit.Record(tx, audit.SystemAdmin, action, event.ID, before, &event); err != nil {
+			return err
+		}
+		log.Printf("Scheduler set event %d published=%t", event.ID, published)
+	}
+	return nil
+}
+
+// endOf returns when the event, or the last occurrence of a series, ends.
+// Series that repeat forever never end.
+func endOf(event *models.Event) (time.Time, bool) {
+	if event.RecurrenceRule == "" {
+		return event.EndDate, true
+	}
+	rule, err := recurrence.Parse(event.RecurrenceRule)
+	if err != nil {
+		return time.Time{}, false
+	}
+	last, ok := rule.Last(event.StartDate)
+	if !ok {
+		return time.Time{}, false
+	}
+	return last.Add(event.EndDate.Sub(event.StartDate)), true
+}
+
+func archiveEnded(tx *gorm.DB, now time.Time) error {
+	var events []models.Event
+	if err := tx.Where("archived_at IS NULL AND end_date < ?", now).Find(&events).Error; err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		end, ok := endOf(&event)
+		if !ok || !end.Before(now) {
+			continue
+		}
+		if err := tx.Model(&event).Updates(map[string]interface{}{
+			"is_published": false,
+			"archived_at":  now,
+			"publish_at":   nil,
+			"unpublish_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		log.Printf("Scheduler archived event %d", event.ID)
+	}
+	return nil
+}
@@ -23,7 +23,9 @@ func ConnectDB() {
 		log.Fatal("Database connection is nil after initialization!")
 	}
 
-	err = DB.AutoMigrate(&models.Event{})
+	err = DB.AutoMigrate(&models.Event{}, &models.EventRegistration{}, &models.Ticket{}, &models.EventOccurrenceOverride{},
+		&models.PriceTier{}, &models.GroupDiscount{}, &models.Payment{}, &models.PromoCode{}, &models.PromoRedemption{},
+		&models.EventImage{}, &models.AuditEntry{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -0,0 +1,243 @@
+// Package ical writes RFC 5545 iCalendar documents.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateTimeFormat      = "20060102T150405Z"
+	localDateTimeFormat = "20060102T150405"
+	maxLineOctets       = 75
+)
+
+type Event struct {
+	UID          string
+	Sequence     int
+	Created      time.Time
+	LastModified time.Time
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Categories   string
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID *time.Time
+	// TimeZone, when set, writes DTSTART, DTEND, EXDATE and RECURRENCE-ID as
+	// local times in that zone, so that a recurrence rule repeats at the same
+	// wall-clock time across UTC offset changes. The calendar describes the
+	// zone in a VTIMEZONE.
+	TimeZone *time.Location
+}
+
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// escapeText escapes a TEXT value as described in RFC 5545 section 3.3.11.
+func escapeText(s string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return replacer.Replace(s)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+// timeProperty formats a date-time property of the event, in UTC or in the
+// event's time zone.
+func (e *Event) timeProperty(name string, t time.Time) string {
+	if e.TimeZone == nil {
+		return name + ":" + formatTime(t)
+	}
+	return name + ";TZID=" + e.TimeZone.String() + ":" + t.In(e.TimeZone).Format(localDateTimeFormat)
+}
+
+// times returns every time the event writes.
+func (e *Event) times() []time.Time {
+	times := append([]time.Time{e.Start, e.End}, e.ExDates...)
+	if e.RecurrenceID != nil {
+		times = append(times, *e.RecurrenceID)
+	}
+	return times
+}
+
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+}
+
+// writeTimeZone writes a VTIMEZONE for loc with one observance per UTC
+// offset in effect between from and to. The last observance stays in effect
+// after to, which covers occurrences of open-ended rules as long as the zone
+// does not change its offset again.
+func writeTimeZone(buf *bytes.Buffer, loc *time.Location, from, to time.Time) {
+	writeLine(buf, "BEGIN:VTIMEZONE")
+	writeLine(buf, "TZID:"+loc.String())
+
+	t := from.In(loc)
+	for {
+		name, offset := t.Zone()
+		start, end := t.ZoneBounds()
+		offsetFrom := offset
+		onset := "19700101T000000"
+		if !start.IsZero() {
+			_, offsetFrom = start.Add(-time.Second).Zone()
+			onset = start.In(time.FixedZone("", offsetFrom)).Format(localDateTimeFormat)
+		}
+
+		component := "STANDARD"
+		if t.IsDST() {
+			component = "DAYLIGHT"
+		}
+		writeLine(buf, "BEGIN:"+component)
+		writeLine(buf, "DTSTART:"+onset)
+		writeLine(buf, "TZOFFSETFROM:"+formatOffset(offsetFrom))
+		writeLine(buf, "TZOFFSETTO:"+formatOffset(offset))
+		writeLine(buf, "TZNAME:"+escapeText(name))
+		writeLine(buf, "END:"+component)
+
+		if end.IsZero() || end.After(to) {
+			break
+		}
+		t = end.In(loc)
+	}
+	writeLine(buf, "END:VTIMEZONE")
+}
+
+// writeTimeZones writes a VTIMEZONE for every zone the events use, covering
+// the times they write.
+func (c *Calendar) writeTimeZones(buf *bytes.Buffer) {
+	type span struct {
+		loc      *time.Location
+		from, to time.Time
+	}
+	spans := make(map[string]*span)
+	for i := range c.Events {
+		event := &c.Events[i]
+		if event.TimeZone == nil {
+			continue
+		}
+		zone := spans[event.TimeZone.String()]
+		if zone == nil {
+			zone = &span{loc: event.TimeZone, from: event.Start, to: event.Start}
+			spans[event.TimeZone.String()] = zone
+		}
+		for _, t := range event.times() {
+			if t.Before(zone.from) {
+				zone.from = t
+			}
+			if t.After(zone.to) {
+				zone.to = t
+			}
+		}
+	}
+
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		zone := spans[name]
+		writeTimeZone(buf, zone.loc, zone.from, zone.to)
+	}
+}
+
+// writeLine writes a content line, folding it so that no physical line is
+// longer than 75 octets and no UTF-8 sequence is split.
+func writeLine(buf *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts toward the limit.
+		limit = maxLineOctets - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func (c *Calendar) Bytes() []byte {
+	var buf bytes.Buffer
+	now := time.Now()
+
+	writeLine(&buf, "BEGIN:VCALENDAR")
+	writeLine(&buf, "VERSION:2.0")
+	writeLine(&buf, "PRODID:-//TravelKZ//Events Service//EN")
+	writeLine(&buf, "CALSCALE:GREGORIAN")
+	writeLine(&buf, "METHOD:PUBLISH")
+	if c.Name != "" {
+		writeLine(&buf, "X-WR-CALNAME:"+escapeText(c.Name))
+		writeLine(&buf, "NAME:"+escapeText(c.Name))
+	}
+	writeLine(&buf, "REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	writeLine(&buf, "X-PUBLISHED-TTL:PT1H")
+	c.writeTimeZones(&buf)
+
+	for i := range c.Events {
+		event := &c.Events[i]
+		writeLine(&buf, "BEGIN:VEVENT")
+		writeLine(&buf, "UID:"+event.UID)
+		writeLine(&buf, "DTSTAMP:"+formatTime(now))
+		writeLine(&buf, fmt.Sprintf("SEQUENCE:%d", event.Sequence))
+		if !event.Created.IsZero() {
+			writeLine(&buf, "CREATED:"+formatTime(event.Created))
+		}
+		if !event.LastModified.IsZero() {
+			writeLine(&buf, "LAST-MODIFIED:"+formatTime(event.LastModified))
+		}
+		if event.RecurrenceID != nil {
+			writeLine(&buf, event.timeProperty("RECURRENCE-ID", *event.RecurrenceID))
+		}
+		writeLine(&buf, event.timeProperty("DTSTART", event.Start))
+		if event.End.After(event.Start) {
+			writeLine(&buf, event.timeProperty("DTEND", event.End))
+		}
+		if event.RRule != "" {
+			writeLine(&buf, "RRULE:"+event.RRule)
+		}
+		for _, exDate := range event.ExDates {
+			writeLine(&buf, event.timeProperty("EXDATE", exDate))
+		}
+		writeLine(&buf, "SUMMARY:"+escapeText(event.Summary))
+		if event.Description != "" {
+			writeLine(&buf, "DESCRIPTION:"+escapeText(event.Description))
+		}
+		if event.Location != "" {
+			writeLine(&buf, "LOCATION:"+escapeText(event.Location))
+		}
+		if event.Categories != "" {
+			writeLine(&buf, "CATEGORIES:"+escapeText(event.Categories))
+		}
+		writeLine(&buf, "STATUS:CONFIRMED")
+		writeLine(&buf, "END:VEVENT")
+	}
+
+	writeLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
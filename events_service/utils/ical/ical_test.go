@@ -0,0 +1,236 @@
+package ical
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"a;b,c", `a\;b\,c`},
+		{`C:\tours`, `C:\\tours`},
+		{`\;`, `\\\;`},
+		{"line one\nline two", `line one\nline two`},
+		{"crlf\r\nend", `crlf\nend`},
+		{"cr\rend", `cr\nend`},
+		{"colon: stays", "colon: stays"},
+		{"Алматы, Медеу", `Алматы\, Медеу`},
+	}
+	for _, tt := range tests {
+		if got := escapeText(tt.in); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// unfold reverses line folding as RFC 5545 section 3.1 describes.
+func unfold(s string) string {
+	return strings.ReplaceAll(s, "\r\n ", "")
+}
+
+func TestWriteLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		lines int
+	}{
+		{"short", "SUMMARY:Tour", 1},
+		{"exactly 75 octets", strings.Repeat("a", 75), 1},
+		{"76 octets", strings.Repeat("a", 76), 2},
+		{"fills a continuation line", strings.Repeat("a", 75+74), 2},
+		{"one past a continuation line", strings.Repeat("a", 75+74+1), 3},
+		{"two-byte runes", "DESCRIPTION:" + strings.Repeat("ж", 100), 3},
+		{"three-byte runes", strings.Repeat("€", 60), 3},
+		{"four-byte runes", strings.Repeat("🏔", 40), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeLine(&buf, tt.line)
+			out := buf.String()
+
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output %q does not end with CRLF", out)
+			}
+			physical := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			if len(physical) != tt.lines {
+				t.Errorf("folded into %d lines, want %d", len(physical), tt.lines)
+			}
+			for i, line := range physical {
+				if len(line) > maxLineOctets {
+					t.Errorf("line %d is %d octets", i, len(line))
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space", i)
+				}
+				if !utf8.ValidString(strings.TrimPrefix(line, " ")) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, line)
+				}
+			}
+			if got := strings.TrimSuffix(unfold(out), "\r\n"); got != tt.line {
+				t.Errorf("unfolded to %q, want %q", got, tt.line)
+			}
+		})
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return loc
+}
+
+func TestCalendarBytes(t *testing.T) {
+	almaty := mustLoadLocation(t, "Asia/Almaty")
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, almaty)
+	recurrenceID := start.AddDate(0, 0, 7)
+	oneOff := time.Date(2025, 7, 1, 18, 0, 0, 0, almaty)
+	calendar := Calendar{
+		Name: "Tours, walks; more",
+		Events: []Event{
+			{
+				UID:         "event-1@travelkz",
+				Sequence:    2,
+				Start:       start,
+				End:         start.Add(2 * time.Hour),
+				Summary:     "Medeu; Shymbulak, and back",
+				Description: strings.Repeat("A long day in the mountains.\n", 5),
+				Location:    "Almaty",
+				RRule:       "FREQ=WEEKLY;COUNT=4",
+				ExDates:     []time.Time{start.AddDate(0, 0, 14)},
+				TimeZone:    almaty,
+			},
+			{
+				UID:          "event-1@travelkz",
+				Start:        recurrenceID.Add(time.Hour),
+				End:          recurrenceID.Add(time.Hour),
+				Summary:      "Moved",
+				RecurrenceID: &recurrenceID,
+				TimeZone:     almaty,
+			},
+			{
+				UID:     "event-2@travelkz",
+				Start:   oneOff,
+				End:     oneOff.Add(time.Hour),
+				Summary: "Concert",
+			},
+		},
+	}
+	out := string(calendar.Bytes())
+
+	for i, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line %d is %d octets: %q", i, len(line), line)
+		}
+	}
+
+	unfolded := unfold(out)
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		`X-WR-CALNAME:Tours\, walks\; more` + "\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:Asia/Almaty\r\n",
+		"BEGIN:STANDARD\r\nDTSTART:20240301T000000\r\nTZOFFSETFROM:+0600\r\nTZOFFSETTO:+0500\r\n",
+		"SEQUENCE:2\r\n",
+		"DTSTART;TZID=Asia/Almaty:20250601T100000\r\n",
+		"DTEND;TZID=Asia/Almaty:20250601T120000\r\n",
+		"RRULE:FREQ=WEEKLY;COUNT=4\r\n",
+		"EXDATE;TZID=Asia/Almaty:20250615T100000\r\n",
+		`SUMMARY:Medeu\; Shymbulak\, and back` + "\r\n",
+		"DESCRIPTION:" + strings.Repeat(`A long day in the mountains.\n`, 5) + "\r\n",
+		"RECURRENCE-ID;TZID=Asia/Almaty:20250608T100000\r\n",
+		"DTSTART:20250701T130000Z\r\n",
+		"DTEND:20250701T140000Z\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("calendar does not contain %q", want)
+		}
+	}
+
+	if n := strings.Count(unfolded, "BEGIN:VTIMEZONE"); n != 1 {
+		t.Errorf("calendar has %d VTIMEZONEs, want 1", n)
+	}
+	if strings.Index(unfolded, "END:VTIMEZONE") > strings.Index(unfolded, "BEGIN:VEVENT") {
+		t.Errorf("VTIMEZONE follows the events")
+	}
+	// An event with no length gets no DTEND.
+	if n := strings.Count(unfolded, "DTEND"); n != 2 {
+		t.Errorf("calendar has %d DTEND lines, want 2", n)
+	}
+}
+
+func TestTimeZoneObservances(t *testing.T) {
+	almaty := mustLoadLocation(t, "Asia/Almaty")
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	tests := []struct {
+		name     string
+		loc      *time.Location
+		from, to time.Time
+		want     []string
+	}{
+		{
+			name: "no change in range",
+			loc:  almaty,
+			from: time.Date(2025, 1, 1, 10, 0, 0, 0, almaty),
+			to:   time.Date(2025, 12, 31, 10, 0, 0, 0, almaty),
+			want: []string{"STANDARD 20240301T000000 +0600 +0500"},
+		},
+		{
+			name: "across the 2024 offset change",
+			loc:  almaty,
+			from: time.Date(2024, 2, 1, 10, 0, 0, 0, almaty),
+			to:   time.Date(2024, 4, 1, 10, 0, 0, 0, almaty),
+			want: []string{
+				"STANDARD 20041031T030000 +0700 +0600",
+				"STANDARD 20240301T000000 +0600 +0500",
+			},
+		},
+		{
+			name: "daylight saving time",
+			loc:  berlin,
+			from: time.Date(2025, 3, 1, 10, 0, 0, 0, berlin),
+			to:   time.Date(2025, 11, 1, 10, 0, 0, 0, berlin),
+			want: []string{
+				"STANDARD 20241027T030000 +0200 +0100",
+				"DAYLIGHT 20250330T020000 +0100 +0200",
+				"STANDARD 20251026T030000 +0200 +0100",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeTimeZone(&buf, tt.loc, tt.from, tt.to)
+
+			var got []string
+			var component, onset, from string
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+				name, value, _ := strings.Cut(line, ":")
+				switch name {
+				case "BEGIN":
+					component = value
+				case "DTSTART":
+					onset = value
+				case "TZOFFSETFROM":
+					from = value
+				case "TZOFFSETTO":
+					got = append(got, strings.Join([]string{component, onset, from, value}, " "))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("observances = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+// Package recurrence parses and expands the subset of RFC 5545 RRULE values
+// that events support: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL,
+// COUNT, UNTIL and, for weekly rules, BYDAY.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+
+	// maxOccurrences bounds expansion of rules without COUNT or UNTIL.
+	maxOccurrences = 5000
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Location is the timezone occurrences are computed in, so that a weekly
+// 10:00 tour stays at 10:00 local time.
+var Location = mustLoadLocation("Asia/Almaty")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+type Rule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, args...))
+}
+
+// Parse reads an RRULE value such as "FREQ=WEEKLY;BYDAY=SA,SU;COUNT=10".
+// A leading "RRULE:" is accepted.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, invalid("empty rule")
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, invalid("malformed part %q", part)
+		}
+		key, val := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		switch key {
+		case "FREQ":
+			switch val {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = val
+			default:
+				return nil, invalid("unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, invalid("INTERVAL must be a positive integer")
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, invalid("COUNT must be a positive integer")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, invalid("UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ")
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return nil, invalid("unsupported BYDAY value %q", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		default:
+			return nil, invalid("unsupported part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, invalid("FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, invalid("COUNT and UNTIL cannot both be set")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != FreqWeekly {
+		return nil, invalid("BYDAY is only supported with FREQ=WEEKLY")
+	}
+
+	sort.Slice(rule.ByDay, func(i, j int) bool {
+		return mondayIndex(rule.ByDay[i]) < mondayIndex(rule.ByDay[j])
+	})
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("20060102", value, Location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// A date-only UNTIL includes the whole day.
+	return t.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+// String returns the canonical RRULE value.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, weekday := range r.ByDay {
+			for name, day := range weekdays {
+				if day == weekday {
+					days = append(days, name)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+func mondayIndex(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+// Between returns the start times of occurrences that fall in [from, to).
+// The series start itself is the first occurrence.
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	var result []time.Time
+	r.each(start, func(occurrence time.Time) bool {
+		if !occurrence.Before(to) {
+			return false
+		}
+		if !occurrence.Before(from) {
+			result = append(result, occurrence)
+		}
+		return true
+	})
+	return result
+}
+
+// Includes reports whether t is the start of an occurrence.
+func (r *Rule) Includes(start, t time.Time) bool {
+	found := false
+	r.each(start, func(occurrence time.Time) bool {
+		if occurrence.Equal(t) {
+			found = true
+		}
+		return occurrence.Before(t)
+	})
+	return found
+}
+
+// Last returns the start of the final occurrence, or false if the rule
+// repeats forever.
+func (r *Rule) Last(start time.Time) (time.Time, bool) {
+	if r.Count == 0 && r.Until.IsZero() {
+		return time.Time{}, false
+	}
+	last := start
+	r.each(start, func(occurrence time.Time) bool {
+		last = occurrence
+		return true
+	})
+	return last, true
+}
+
+// each calls fn for each occurrence in order until fn returns false or the
+// rule is exhausted.
+func (r *Rule) each(start time.Time, fn func(time.Time) bool) {
+	local := start.In(Location)
+	emitted := 0
+
+	emit := func(occurrence time.Time) bool {
+		if occurrence.Before(local) {
+			return true
+		}
+		if !r.Until.IsZero() && occurrence.After(r.Until) {
+			return false
+		}
+		if r.Count > 0 && emitted >= r.Count {
+			return false
+		}
+		if emitted >= maxOccurrences {
+			return false
+		}
+		emitted++
+		return fn(occurrence)
+	}
+
+	for period := 0; ; period++ {
+		switch r.Freq {
+		case FreqDaily:
+			if !emit(local.AddDate(0, 0, period*r.Interval)) {
+				return
+			}
+		case FreqWeekly:
+			if len(r.ByDay) == 0 {
+				if !emit(local.AddDate(0, 0, 7*period*r.Interval)) {
+					return
+				}
+				continue
+			}
+			weekStart := local.AddDate(0, 0, 7*period*r.Interval-mondayIndex(local.Weekday()))
+			for _, day := range r.ByDay {
+				if !emit(weekStart.AddDate(0, 0, mondayIndex(day))) {
+					return
+				}
+			}
+		case FreqMonthly:
+			occurrence, ok := sameDayIn(local, 0, period*r.Interval)
+			if ok && !emit(occurrence) {
+				return
+			}
+			if period > maxOccurrences*12 {
+				return
+			}
+		case FreqYearly:
+			occurrence, ok := sameDayIn(local, period*r.Interval, 0)
+			if ok && !emit(occurrence) {
+				return
+			}
+			if period > maxOccurrences*4 {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// sameDayIn moves t by whole years and months, reporting false when the
+// target month has no such day (for example the 31st in April), in which case
+// RFC 5545 skips the occurrence.
+func sameDayIn(t time.Time, years, months int) (time.Time, bool) {
+	first := time.Date(t.Year()+years, t.Month()+time.Month(months), 1,
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	candidate := first.AddDate(0, 0, t.Day()-1)
+	return candidate, candidate.Month() == first.Month()
+}
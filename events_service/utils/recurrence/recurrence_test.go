@@ -0,0 +1,247 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, value string) *Rule {
+	t.Helper()
+	rule, err := Parse(value)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", value, err)
+	}
+	return rule
+}
+
+func formatAll(times []time.Time) []string {
+	result := make([]string, len(times))
+	for i, occurrence := range times {
+		result[i] = occurrence.Format(time.RFC3339)
+	}
+	return result
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		invalid bool
+	}{
+		{value: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{value: "RRULE:freq=weekly;byday=su,mo;count=4", want: "FREQ=WEEKLY;COUNT=4;BYDAY=MO,SU"},
+		{value: "FREQ=MONTHLY;INTERVAL=2;UNTIL=20250101T000000Z", want: "FREQ=MONTHLY;INTERVAL=2;UNTIL=20250101T000000Z"},
+		{value: "FREQ=DAILY;UNTIL=20250101", want: "FREQ=DAILY;UNTIL=20250101T185959Z"},
+		{value: "", invalid: true},
+		{value: "INTERVAL=2", invalid: true},
+		{value: "FREQ=HOURLY", invalid: true},
+		{value: "FREQ=DAILY;INTERVAL=0", invalid: true},
+		{value: "FREQ=DAILY;COUNT=-1", invalid: true},
+		{value: "FREQ=DAILY;COUNT=2;UNTIL=20250101", invalid: true},
+		{value: "FREQ=DAILY;BYDAY=MO", invalid: true},
+		{value: "FREQ=WEEKLY;BYDAY=XX", invalid: true},
+		{value: "FREQ=DAILY;BYMONTH=1", invalid: true},
+		{value: "FREQ", invalid: true},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.value)
+		if tt.invalid {
+			if !errors.Is(err, ErrInvalidRule) {
+				t.Errorf("Parse(%q) error = %v, want ErrInvalidRule", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.value, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	start := time.Date(2025, 1, 6, 10, 0, 0, 0, Location) // a Monday
+	from := start
+	to := start.AddDate(1, 0, 0)
+
+	tests := []struct {
+		name string
+		rule string
+		want []string
+	}{
+		{
+			name: "daily count",
+			rule: "FREQ=DAILY;COUNT=3",
+			want: []string{"2025-01-06T10:00:00+05:00", "2025-01-07T10:00:00+05:00", "2025-01-08T10:00:00+05:00"},
+		},
+		{
+			name: "daily interval",
+			rule: "FREQ=DAILY;INTERVAL=3;COUNT=3",
+			want: []string{"2025-01-06T10:00:00+05:00", "2025-01-09T10:00:00+05:00", "2025-01-12T10:00:00+05:00"},
+		},
+		{
+			name: "date-only until includes the whole day",
+			rule: "FREQ=DAILY;UNTIL=20250108",
+			want: []string{"2025-01-06T10:00:00+05:00", "2025-01-07T10:00:00+05:00", "2025-01-08T10:00:00+05:00"},
+		},
+		{
+			name: "until before the occurrence time excludes that day",
+			rule: "FREQ=DAILY;UNTIL=20250108T040000Z",
+			want: []string{"2025-01-06T10:00:00+05:00", "2025-01-07T10:00:00+05:00"},
+		},
+		{
+			name: "until exactly at an occurrence includes it",
+			rule: "FREQ=DAILY;UNTIL=20250107T050000Z",
+			want: []string{"2025-01-06T10:00:00+05:00", "2025-01-07T10:00:00+05:00"},
+		},
+		{
+			name: "weekly byday counts every day",
+			rule: "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=4",
+			want: []string{"2025-01-06T10:00:00+05:00", "2025-01-08T10:00:00+05:00", "2025-01-10T10:00:00+05:00", "2025-01-13T10:00:00+05:00"},
+		},
+		{
+			name: "weekly interval",
+			rule: "FREQ=WEEKLY;INTERVAL=2;COUNT=3",
+			want: []string{"2025-01-06T10:00:00+05:00", "2025-01-20T10:00:00+05:00", "2025-02-03T10:00:00+05:00"},
+		},
+		{
+			name: "weekly byday until",
+			rule: "FREQ=WEEKLY;BYDAY=SA,SU;UNTIL=20250119",
+			want: []string{"2025-01-11T10:00:00+05:00", "2025-01-12T10:00:00+05:00", "2025-01-18T10:00:00+05:00", "2025-01-19T10:00:00+05:00"},
+		},
+		{
+			name: "yearly",
+			rule: "FREQ=YEARLY;COUNT=2",
+			want: []string{"2025-01-06T10:00:00+05:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatAll(mustParse(t, tt.rule).Between(start, from, to))
+			if !equal(got, tt.want) {
+				t.Errorf("Between = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonthlySkipsMissingDays(t *testing.T) {
+	start := time.Date(2025, 1, 31, 18, 30, 0, 0, Location)
+	got := formatAll(mustParse(t, "FREQ=MONTHLY;COUNT=4").Between(start, start, start.AddDate(2, 0, 0)))
+	want := []string{
+		"2025-01-31T18:30:00+05:00",
+		"2025-03-31T18:30:00+05:00",
+		"2025-05-31T18:30:00+05:00",
+		"2025-07-31T18:30:00+05:00",
+	}
+	if !equal(got, want) {
+		t.Errorf("Between = %v, want %v", got, want)
+	}
+}
+
+func TestYearlyLeapDay(t *testing.T) {
+	start := time.Date(2024, 2, 29, 12, 0, 0, 0, Location)
+	got := formatAll(mustParse(t, "FREQ=YEARLY;COUNT=2").Between(start, start, start.AddDate(10, 0, 0)))
+	want := []string{"2024-02-29T12:00:00+06:00", "2028-02-29T12:00:00+05:00"}
+	if !equal(got, want) {
+		t.Errorf("Between = %v, want %v", got, want)
+	}
+}
+
+// Occurrences keep their local wall-clock time when the UTC offset changes,
+// both across a daylight saving transition and across Kazakhstan's 2024
+// switch from UTC+6 to UTC+5.
+func TestBetweenKeepsLocalTimeAcrossOffsetChanges(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		start    time.Time
+		rule     string
+		want     []string
+	}{
+		{
+			name:     "spring forward",
+			location: berlin,
+			start:    time.Date(2025, 3, 29, 10, 0, 0, 0, berlin),
+			rule:     "FREQ=DAILY;COUNT=3",
+			want:     []string{"2025-03-29T10:00:00+01:00", "2025-03-30T10:00:00+02:00", "2025-03-31T10:00:00+02:00"},
+		},
+		{
+			name:     "fall back weekly",
+			location: berlin,
+			start:    time.Date(2025, 10, 19, 9, 0, 0, 0, berlin),
+			rule:     "FREQ=WEEKLY;COUNT=3",
+			want:     []string{"2025-10-19T09:00:00+02:00", "2025-10-26T09:00:00+01:00", "2025-11-02T09:00:00+01:00"},
+		},
+		{
+			name:     "almaty offset change",
+			location: Location,
+			start:    time.Date(2024, 2, 29, 10, 0, 0, 0, Location),
+			rule:     "FREQ=DAILY;COUNT=2",
+			want:     []string{"2024-02-29T10:00:00+06:00", "2024-03-01T10:00:00+05:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(saved *time.Location) { Location = saved }(Location)
+			Location = tt.location
+
+			rule := mustParse(t, tt.rule)
+			got := formatAll(rule.Between(tt.start, tt.start, tt.start.AddDate(0, 1, 0)))
+			if !equal(got, tt.want) {
+				t.Errorf("Between = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludesAndLast(t *testing.T) {
+	start := time.Date(2025, 1, 6, 10, 0, 0, 0, Location)
+	rule := mustParse(t, "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=5")
+
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{start, true},
+		{start.AddDate(0, 0, 3), true},
+		{start.AddDate(0, 0, 1), false},
+		{start.Add(time.Hour), false},
+		{start.AddDate(0, 0, 14), true},
+		{start.AddDate(0, 0, 17), false}, // sixth occurrence, beyond COUNT
+		{start.AddDate(0, 0, -4), false},
+	}
+	for _, tt := range tests {
+		if got := rule.Includes(start, tt.t); got != tt.want {
+			t.Errorf("Includes(%s) = %v, want %v", tt.t.Format(time.RFC3339), got, tt.want)
+		}
+	}
+
+	last, ok := rule.Last(start)
+	if !ok || !last.Equal(start.AddDate(0, 0, 14)) {
+		t.Errorf("Last = %s, %v, want %s", last.Format(time.RFC3339), ok, start.AddDate(0, 0, 14).Format(time.RFC3339))
+	}
+	if _, ok := mustParse(t, "FREQ=DAILY").Last(start); ok {
+		t.Error("Last of an endless rule reported an occurrence")
+	}
+}
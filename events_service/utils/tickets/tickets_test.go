@@ -0,0 +1,129 @@
+package tickets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func setup(t *testing.T, secret string) {
+	t.Helper()
+	t.Setenv("TICKET_SIGNING_SECRET", secret)
+	if err := Setup(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetupRequiresSecret(t *testing.T) {
+	t.Setenv("TICKET_SIGNING_SECRET", "")
+	if err := Setup(); err == nil {
+		t.Error("Setup succeeded without TICKET_SIGNING_SECRET")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	setup(t, "test-secret")
+
+	tests := []struct {
+		eventID, userID uint
+	}{
+		{1, 1},
+		{42, 7},
+		{4294967295, 123456789},
+	}
+	for _, tt := range tests {
+		code, err := NewCode(tt.eventID, tt.userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := Verify(code, tt.eventID, EventKey(tt.eventID))
+		if err != nil {
+			t.Errorf("Verify(%q): %v", code, err)
+			continue
+		}
+		if claims != (Claims{EventID: tt.eventID, UserID: tt.userID}) {
+			t.Errorf("Verify(%q) = %+v, want event %d user %d", code, claims, tt.eventID, tt.userID)
+		}
+	}
+}
+
+func TestCodesAreUnique(t *testing.T) {
+	setup(t, "test-secret")
+
+	first, _ := NewCode(1, 1)
+	second, _ := NewCode(1, 1)
+	if first == second {
+		t.Errorf("two codes for the same seat are equal: %q", first)
+	}
+}
+
+// replacePart swaps one dot-separated part of a code.
+func replacePart(code string, index int, value string) string {
+	parts := strings.Split(code, ".")
+	parts[index] = value
+	return strings.Join(parts, ".")
+}
+
+func TestVerifyRejects(t *testing.T) {
+	setup(t, "test-secret")
+	code, err := NewCode(5, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := code[strings.LastIndex(code, ".")+1:]
+	flipped := "A"
+	if signature[0] == 'A' {
+		flipped = "B"
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		eventID uint
+		key     []byte
+		want    error
+	}{
+		{"other user", replacePart(code, 2, "10"), 5, EventKey(5), ErrInvalidSignature},
+		{"other event in code", replacePart(code, 1, "6"), 6, EventKey(6), ErrInvalidSignature},
+		{"other nonce", replacePart(code, 3, "000000000000000000000000"), 5, EventKey(5), ErrInvalidSignature},
+		{"altered signature", replacePart(code, 4, flipped+signature[1:]), 5, EventKey(5), ErrInvalidSignature},
+		{"missing signature", replacePart(code, 4, ""), 5, EventKey(5), ErrInvalidSignature},
+		{"other event's key", code, 5, EventKey(6), ErrInvalidSignature},
+		{"scanned at another event", code, 6, EventKey(5), ErrWrongEvent},
+		{"unknown version", replacePart(code, 0, "T2"), 5, EventKey(5), ErrMalformedCode},
+		{"too few parts", strings.Join(strings.Split(code, ".")[:4], "."), 5, EventKey(5), ErrMalformedCode},
+		{"too many parts", code + ".x", 5, EventKey(5), ErrMalformedCode},
+		{"empty", "", 5, EventKey(5), ErrMalformedCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(tt.code, tt.eventID, tt.key); !errors.Is(err, tt.want) {
+				t.Errorf("Verify(%q) error = %v, want %v", tt.code, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsOtherSecret(t *testing.T) {
+	setup(t, "test-secret")
+	code, err := NewCode(5, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setup(t, "another-secret")
+	if _, err := Verify(code, 5, EventKey(5)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with another master secret error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+// A signed payload with a non-numeric ID is still malformed.
+func TestVerifyRejectsSignedGarbage(t *testing.T) {
+	setup(t, "test-secret")
+	key := EventKey(5)
+	payload := "T1.5.nine.00"
+	code := payload + "." + sign(key, payload)
+	if _, err := Verify(code, 5, key); !errors.Is(err, ErrMalformedCode) {
+		t.Errorf("Verify(%q) error = %v, want ErrMalformedCode", code, err)
+	}
+}
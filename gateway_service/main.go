@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sort"
 	"strings"
 
 	middlewares "gateway_service/middleware"
@@ -31,6 +32,13 @@ var services = map[string]ServiceConfig{
 			"/profile",
 			"/validate-admin",
 			"/validate-session",
+			"/verify-email",
+			"/password",
+			"/sessions",
+			"/2fa",
+			"/admin/roles",
+			"/admin/users",
+			"/admin/auth-events",
 		},
 		Auth: false, // Base auth paths don't need authentication
 	},
@@ -38,27 +46,48 @@ var services = map[string]ServiceConfig{
 		URL: "http://events-service:8083",
 		Paths: []string{
 			"/admin/events",
+			"/admin/audit/events",
 			"/events",
-			"/uploads/events", // ✅ Proxy uploads/events to events-service
+			"/payments",
 		},
 		Auth: false,
 	},
-	
+
 	"attractions": {
 		URL: "http://attraction-service:8085",
 		Paths: []string{
 			"/admin/attractions",
+			"/admin/audit/attractions",
 			"/attractions",
-			"/uploads", // ✅ Correct path for static files
+			"/attractions/", // Public detail, nearby search and export
+		},
+		Auth: false,
+	},
+
+	"media": {
+		URL: "http://media-service:8086",
+		Paths: []string{
+			"/admin/media",
+			"/media",
+			"/uploads", // Files uploaded before the media service existed
 		},
 		Auth: false,
 	},
 }
 
 var pathAuthOverrides = map[string]bool{
-	"/admin/events":      true,
-	"/admin/attractions": true,
-	"/attractions":       true,
+	"/admin/events":            true,
+	"/admin/attractions":       true,
+	"/admin/media":             true,
+	"/admin/audit/events":      true,
+	"/admin/audit/attractions": true,
+	"/admin/roles":             true,
+	"/admin/users":             true,
+	"/admin/auth-events":       true,
+	"/attractions":             true,
+	// Every path below /attractions/ is public: the detail view, nearby
+	// search and exports. Only the bare listing requires a session.
+	"/attractions/": false,
 }
 
 func main() {
@@ -73,22 +102,38 @@ func main() {
 	log.Fatal(http.ListenAndServe(":8080", handler))
 }
 
+type route struct {
+	path   string
+	config ServiceConfig
+}
+
 func setupRoutes(r *mux.Router) {
+	var routes []route
 	for _, config := range services {
 		for _, path := range config.Paths {
-			handler := createProxyHandler(config.URL)
+			routes = append(routes, route{path: path, config: config})
+		}
+	}
 
-			requiresAuth := config.Auth
-			if override, exists := pathAuthOverrides[path]; exists {
-				requiresAuth = override
-			}
+	// mux matches in registration order, so longer (more specific) prefixes
+	// must be registered first to take precedence over their parents.
+	sort.Slice(routes, func(i, j int) bool {
+		return len(routes[i].path) > len(routes[j].path)
+	})
 
-			if requiresAuth {
-				handler = middlewares.AuthMiddleware(handler)
-			}
+	for _, rt := range routes {
+		handler := createProxyHandler(rt.config.URL)
+
+		requiresAuth := rt.config.Auth
+		if override, exists := pathAuthOverrides[rt.path]; exists {
+			requiresAuth = override
+		}
 
-			r.PathPrefix(path).Handler(handler)
+		if requiresAuth {
+			handler = middlewares.AuthMiddleware(handler)
 		}
+
+		r.PathPrefix(rt.path).Handler(handler)
 	}
 }
 
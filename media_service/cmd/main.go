@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"diplomaPorject/backend/media_service/internal/gc"
+	"diplomaPorject/backend/media_service/internal/routes"
+	"diplomaPorject/backend/media_service/internal/storage"
+	"diplomaPorject/backend/media_service/utils/db"
+	"log"
+	"net/http"
+)
+
+func main() {
+	db.ConnectDB()
+	if err := storage.Setup(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	gc.Start(context.Background())
+	router := routes.SetupRoutes()
+	log.Println("Media service running on port 8086...")
+	log.Fatal(http.ListenAndServe(":8086", router))
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/media_service/internal/imageproc"
+	"diplomaPorject/backend/media_service/internal/models"
+	"diplomaPorject/backend/media_service/internal/storage"
+	"diplomaPorject/backend/media_service/utils/db"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// Media URLs are content addressed, so a URL never changes meaning and
+	// clients may cache it forever.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// Legacy uploads predate content addressing and are cached for a day.
+	legacyCacheControl = "public, max-age=86400"
+
+	mediaPrefix = storage.MediaPrefix
+)
+
+// storeFile saves data in the active storage and records it, returning its
+// public URL. The row is written first, in its own statement: it takes the
+// file out of quarantine and resets UploadedAt, so no sweep quarantines it
+// again for a while. A sweep deletes an object only after locking its row
+// and checking it is still quarantined; if that sweep is under way the write
+// waits for it to commit, and the file is then put back.
+func storeFile(r *http.Request, data []byte, ext, contentType string) (string, error) {
+	key := storage.Key(data, ext)
+	object := models.MediaObject{
+		Key:         key,
+		Size:        int64(len(data)),
+		ContentType: contentType,
+		UploadedAt:  time.Now(),
+	}
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"uploaded_at":    object.UploadedAt,
+			"quarantined_at": nil,
+		}),
+	}).Create(&object).Error
+	if err != nil {
+		return "", err
+	}
+	if err := storage.Active().Put(r.Context(), key, data, contentType); err != nil {
+		return "", err
+	}
+	return mediaPrefix + key, nil
+}
+
+// UploadImage takes a multipart "file", renders its variants and stores
+// them. Identical renders are stored once, so uploading the same image twice
+// returns the same URLs.
+func UploadImage(w http.ResponseWriter, r *http.Request) {
+	// Leave room for the multipart framing around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, imageproc.MaxUploadBytes+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, imageproc.ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "No image file provided", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	rendered, err := imageproc.Process(file)
+	switch {
+	case errors.Is(err, imageproc.ErrUnsupportedType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	case errors.Is(err, imageproc.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		log.Printf("Failed to process image: %v", err)
+		http.Error(w, "Failed to process image", http.StatusInternalServerError)
+		return
+	}
+
+	variants := make(models.ImageVariants, len(rendered))
+	for _, variant := range rendered {
+		saved := models.ImageVariant{Width: variant.Width, Height: variant.Height}
+		if saved.URL, err = storeFile(r, variant.Data, variant.Ext, variant.ContentType); err == nil && variant.WebP != nil {
+			saved.WebPURL, err = storeFile(r, variant.WebP, ".webp", "image/webp")
+		}
+		if err != nil {
+			log.Printf("Failed to store image: %v", err)
+			http.Error(w, "Failed to store image", http.StatusInternalServerError)
+			return
+		}
+		variants[variant.Name] = saved
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(variants)
+}
+
+// ServeMedia serves a stored object. The content hash doubles as the ETag.
+func ServeMedia(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	if !storage.ValidKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+
+	object, info, err := storage.Active().Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read %s: %v", key, err)
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	defer object.Close()
+
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Cache-Control", immutableCacheControl)
+	w.Header().Set("ETag", `"`+storage.Hash(key)+`"`)
+	http.ServeContent(w, r, key, info.ModTime, object)
+}
+
+// ServeLegacyUpload serves files that the attraction and events services
+// wrote to the shared upload directory before images moved to storage.
+func ServeLegacyUpload(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Clean("/" + strings.TrimPrefix(r.URL.Path, storage.LegacyPrefix))
+	if storage.IsQuarantined(name) {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := os.Open(filepath.Join(storage.LegacyDir, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", legacyCacheControl)
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), file)
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/media_service/internal/gc"
+	"diplomaPorject/backend/media_service/utils/db"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultUsageLimit = 100
+	maxUsageLimit     = 1000
+)
+
+// SweepStorage runs a garbage collection sweep now. With dry_run=true it
+// only reports what a sweep would do.
+func SweepStorage(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	report, err := gc.Sweep(r.Context(), time.Now(), dryRun)
+	if errors.Is(err, gc.ErrSweepRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Storage sweep failed: %v", err)
+		http.Error(w, "Storage sweep failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// StorageUsage reports the storage used per service and per entity. limit
+// caps the number of entities listed, largest first.
+func StorageUsage(w http.ResponseWriter, r *http.Request) {
+	limit := defaultUsageLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxUsageLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	report, err := gc.StorageUsage(db.DB, limit)
+	if err != nil {
+		log.Printf("Failed to compute storage usage: %v", err)
+		http.Error(w, "Failed to compute storage usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,331 @@
+// Package gc collects files that no row references any more. A sweep moves
+// orphaned files to quarantine; they are deleted by a later sweep once the
+// grace period has passed, and restored if something references them again
+// in the meantime.
+package gc
+
+import (
+	"context"
+	"diplomaPorject/backend/media_service/internal/models"
+	"diplomaPorject/backend/media_service/internal/storage"
+	"diplomaPorject/backend/media_service/utils/db"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockKey is the Postgres advisory lock held while a sweep runs. The
+// services share a database, so it differs from the schedulers' keys.
+const lockKey int64 = 0x54524b5a0003
+
+const (
+	defaultInterval    = 6 * time.Hour
+	defaultGracePeriod = 7 * 24 * time.Hour
+
+	// minAge spares fresh uploads: images are uploaded before the row that
+	// references them is saved.
+	minAge = time.Hour
+)
+
+const (
+	ActionQuarantine = "quarantine"
+	ActionRestore    = "restore"
+	ActionDelete     = "delete"
+)
+
+var ErrSweepRunning = errors.New("a storage sweep is already running")
+
+// Item is one file a sweep acted on, or would act on in a dry run.
+type Item struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	Size   int64  `json:"size"`
+}
+
+type Report struct {
+	DryRun      bool   `json:"dry_run"`
+	Scanned     int    `json:"scanned"`
+	Referenced  int    `json:"referenced"`
+	Quarantined int    `json:"quarantined"`
+	Restored    int    `json:"restored"`
+	Deleted     int    `json:"deleted"`
+	FreedBytes  int64  `json:"freed_bytes"`
+	Items       []Item `json:"items"`
+}
+
+func (r *Report) add(path, action string, size int64) {
+	r.Items = append(r.Items, Item{Path: path, Action: action, Size: size})
+	switch action {
+	case ActionQuarantine:
+		r.Quarantined++
+	case ActionRestore:
+		r.Restored++
+	case ActionDelete:
+		r.Deleted++
+		r.FreedBytes += size
+	}
+}
+
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// GracePeriod is how long a file stays in quarantine, set with
+// GC_GRACE_PERIOD.
+func GracePeriod() time.Duration {
+	return durationEnv("GC_GRACE_PERIOD", defaultGracePeriod)
+}
+
+// Start sweeps in the background every GC_INTERVAL until ctx is cancelled.
+func Start(ctx context.Context) {
+	every := durationEnv("GC_INTERVAL", defaultInterval)
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			report, err := Sweep(ctx, time.Now(), false)
+			switch {
+			case errors.Is(err, ErrSweepRunning):
+			case err != nil:
+				log.Printf("Storage sweep failed: %v", err)
+			default:
+				log.Printf("Storage sweep: %d quarantined, %d restored, %d deleted (%d bytes freed)",
+					report.Quarantined, report.Restored, report.Deleted, report.FreedBytes)
+			}
+		}
+	}()
+	log.Printf("Storage sweep running every %s", every)
+}
+
+// Sweep checks every stored object and legacy upload against the rows that
+// reference files. With dryRun nothing is changed and the report lists what
+// would be done.
+func Sweep(ctx context.Context, now time.Time, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Items: []Item{}}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", lockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return ErrSweepRunning
+		}
+
+		refs, err := References(tx)
+		if err != nil {
+			return err
+		}
+		s := &sweep{ctx: ctx, tx: tx, now: now, grace: GracePeriod(), dryRun: dryRun, refs: refs, report: report}
+		if err := s.objects(); err != nil {
+			return err
+		}
+		return s.legacyFiles()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type sweep struct {
+	ctx    context.Context
+	tx     *gorm.DB
+	now    time.Time
+	grace  time.Duration
+	dryRun bool
+	refs   map[string][]Owner
+	report *Report
+}
+
+func (s *sweep) objects() error {
+	var objects []models.MediaObject
+	if err := s.tx.Find(&objects).Error; err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		s.report.Scanned++
+		path := storage.MediaPrefix + object.Key
+		switch {
+		case len(s.refs[path]) > 0:
+			s.report.Referenced++
+			if object.QuarantinedAt == nil {
+				continue
+			}
+			s.report.add(path, ActionRestore, object.Size)
+			if !s.dryRun {
+				if err := s.tx.Model(&object).Update("quarantined_at", nil).Error; err != nil {
+					return err
+				}
+			}
+
+		case object.QuarantinedAt == nil:
+			if s.now.Sub(object.UploadedAt) < minAge {
+				continue
+			}
+			s.report.add(path, ActionQuarantine, object.Size)
+			if !s.dryRun {
+				if err := s.tx.Model(&object).Update("quarantined_at", s.now).Error; err != nil {
+					return err
+				}
+			}
+
+		case s.now.Sub(*object.QuarantinedAt) >= s.grace:
+			if s.dryRun {
+				s.report.add(path, ActionDelete, object.Size)
+				continue
+			}
+			deleted, err := s.deleteObject(object.Key)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				s.report.add(path, ActionDelete, object.Size)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteObject removes a quarantined object. The row is locked and checked
+// again, since an upload of the same content may have taken it out of
+// quarantine since it was read; that upload waits for the sweep and then
+// stores the file again.
+func (s *sweep) deleteObject(key string) (bool, error) {
+	var object models.MediaObject
+	err := s.tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("key = ? AND quarantined_at <= ?", key, s.now.Add(-s.grace)).
+		First(&object).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := storage.Active().Delete(s.ctx, key); err != nil {
+		return false, err
+	}
+	return true, s.tx.Delete(&object).Error
+}
+
+// legacyFiles sweeps the upload directory of files written before the media
+// service existed. Quarantined files are moved into QuarantineDir, which is
+// not served, and their modification time records when.
+func (s *sweep) legacyFiles() error {
+	quarantine := storage.QuarantineDir()
+
+	// Quarantine is swept first so files quarantined below are not seen
+	// twice; restored files are skipped in the second walk for the same
+	// reason.
+	restored := make(map[string]bool)
+	err := walkFiles(quarantine, "", func(name string, info fs.FileInfo) error {
+		s.report.Scanned++
+		path := storage.LegacyPrefix + name
+		switch {
+		case len(s.refs[path]) > 0:
+			s.report.Referenced++
+			s.report.add(path, ActionRestore, info.Size())
+			restored[name] = true
+			if s.dryRun {
+				return nil
+			}
+			return s.moveFile(filepath.Join(quarantine, name), filepath.Join(storage.LegacyDir, name))
+
+		case s.now.Sub(info.ModTime()) >= s.grace:
+			s.report.add(path, ActionDelete, info.Size())
+			if s.dryRun {
+				return nil
+			}
+			return os.Remove(filepath.Join(quarantine, name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return walkFiles(storage.LegacyDir, quarantine, func(name string, info fs.FileInfo) error {
+		if restored[name] {
+			return nil
+		}
+		s.report.Scanned++
+		path := storage.LegacyPrefix + name
+		if len(s.refs[path]) > 0 {
+			s.report.Referenced++
+			return nil
+		}
+		if s.now.Sub(info.ModTime()) < minAge {
+			return nil
+		}
+		s.report.add(path, ActionQuarantine, info.Size())
+		if s.dryRun {
+			return nil
+		}
+		return s.moveFile(filepath.Join(storage.LegacyDir, name), filepath.Join(quarantine, name))
+	})
+}
+
+// moveFile renames a file, creating the target directory, and stamps it
+// with the sweep time.
+func (s *sweep) moveFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	return os.Chtimes(to, s.now, s.now)
+}
+
+// walkFiles calls fn for every regular file under root with its slash
+// separated path relative to root. The skip directory is not entered; a
+// missing root is treated as empty.
+func walkFiles(root, skip string, fn func(name string, info fs.FileInfo) error) error {
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == skip {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(name), info)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package gc
+
+import (
+	"diplomaPorject/backend/media_service/internal/storage"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"net/url"
+	"strings"
+)
+
+// Owner is an entity whose row references a file.
+type Owner struct {
+	Service  string `json:"service"`
+	Entity   string `json:"entity"`
+	EntityID uint   `json:"entity_id"`
+}
+
+// referenceSource is a query over another service's tables, which share the
+// database with the media service. Each query returns entity_id, url and
+// variants; rows of soft-deleted entities are left out so their files can be
+// collected.
+type referenceSource struct {
+	Service string
+	Entity  string
+	Query   string
+}
+
+var referenceSources = []referenceSource{
+	{
+		Service: "attractions",
+		Entity:  "attraction",
+		Query: `SELECT id AS entity_id, image_url AS url, image_variants AS variants
+			FROM attractions WHERE deleted_at IS NULL`,
+	},
+	{
+		Service: "attractions",
+		Entity:  "attraction",
+		Query: `SELECT i.attraction_id AS entity_id, i.url, i.variants
+			FROM attraction_images i JOIN attractions a ON a.id = i.attraction_id
+			WHERE i.deleted_at IS NULL AND a.deleted_at IS NULL`,
+	},
+	{
+		Service: "events",
+		Entity:  "event",
+		Query: `SELECT id AS entity_id, image_url AS url, image_variants AS variants
+			FROM events WHERE deleted_at IS NULL`,
+	},
+	{
+		Service: "events",
+		Entity:  "event",
+		Query: `SELECT i.event_id AS entity_id, i.url, i.variants
+			FROM event_images i JOIN events e ON e.id = i.event_id
+			WHERE i.deleted_at IS NULL AND e.deleted_at IS NULL`,
+	},
+}
+
+type referenceRow struct {
+	EntityID uint
+	URL      *string
+	Variants *string
+}
+
+// imageVariants mirrors the variants column written by the other services.
+type imageVariants map[string]struct {
+	URL     string `json:"url"`
+	WebPURL string `json:"webp_url"`
+}
+
+// References maps the path of every file in use ("/media/<key>" or
+// "/uploads/<name>") to the entities using it. Any failed query fails the
+// whole collection: a partial view would make live files look orphaned.
+func References(tx *gorm.DB) (map[string][]Owner, error) {
+	refs := make(map[string][]Owner)
+	for _, source := range referenceSources {
+		var rows []referenceRow
+		if err := tx.Raw(source.Query).Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read %s references: %w", source.Entity, err)
+		}
+
+		for _, row := range rows {
+			owner := Owner{Service: source.Service, Entity: source.Entity, EntityID: row.EntityID}
+			var urls []string
+			if row.URL != nil {
+				urls = append(urls, *row.URL)
+			}
+			if row.Variants != nil {
+				var variants imageVariants
+				if err := json.Unmarshal([]byte(*row.Variants), &variants); err != nil {
+					return nil, fmt.Errorf("invalid image variants on %s %d: %w", source.Entity, row.EntityID, err)
+				}
+				for _, variant := range variants {
+					urls = append(urls, variant.URL, variant.WebPURL)
+				}
+			}
+
+			for _, raw := range urls {
+				if path := filePath(raw); path != "" && !hasOwner(refs[path], owner) {
+					refs[path] = append(refs[path], owner)
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+// filePath returns the path of a URL served by the media service, or "" for
+// external URLs. Absolute URLs are accepted since imports may contain them.
+func filePath(raw string) string {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	if strings.HasPrefix(parsed.Path, storage.MediaPrefix) || strings.HasPrefix(parsed.Path, storage.LegacyPrefix) {
+		return parsed.Path
+	}
+	return ""
+}
+
+func hasOwner(owners []Owner, owner Owner) bool {
+	for _, o := range owners {
+		if o == owner {
+			return true
+		}
+	}
+	return false
+}
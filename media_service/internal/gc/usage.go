@@ -0,0 +1,131 @@
+package gc
+
+import (
+	"diplomaPorject/backend/media_service/internal/models"
+	"diplomaPorject/backend/media_service/internal/storage"
+	"gorm.io/gorm"
+	"io/fs"
+	"sort"
+)
+
+// Usage is the number and total size of a set of files. A file shared by
+// several entities counts once for each of them but once per service.
+type Usage struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+type ServiceUsage struct {
+	Service string `json:"service"`
+	Usage
+}
+
+type EntityUsage struct {
+	Owner
+	Usage
+}
+
+type UsageReport struct {
+	Total        Usage          `json:"total"`
+	Services     []ServiceUsage `json:"services"`
+	Entities     []EntityUsage  `json:"entities"`
+	Unreferenced Usage          `json:"unreferenced"`
+	Quarantined  Usage          `json:"quarantined"`
+}
+
+// StorageUsage reports the space taken by every file, split by the services
+// and entities referencing it. Entities are sorted by size, largest first,
+// and cut off after limit.
+func StorageUsage(tx *gorm.DB, limit int) (*UsageReport, error) {
+	refs, err := References(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UsageReport{Services: []ServiceUsage{}, Entities: []EntityUsage{}}
+	sizes := make(map[string]int64)
+
+	var objects []models.MediaObject
+	if err := tx.Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		sizes[storage.MediaPrefix+object.Key] = object.Size
+		if object.QuarantinedAt != nil {
+			report.Quarantined.add(object.Size)
+		}
+	}
+
+	quarantine := storage.QuarantineDir()
+	err = walkFiles(storage.LegacyDir, quarantine, func(name string, info fs.FileInfo) error {
+		sizes[storage.LegacyPrefix+name] = info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = walkFiles(quarantine, "", func(name string, info fs.FileInfo) error {
+		report.Quarantined.add(info.Size())
+		report.Total.add(info.Size())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string]*Usage)
+	entities := make(map[Owner]*Usage)
+	for path, size := range sizes {
+		report.Total.add(size)
+		owners := refs[path]
+		if len(owners) == 0 {
+			report.Unreferenced.add(size)
+			continue
+		}
+
+		counted := make(map[string]bool)
+		for _, owner := range owners {
+			if !counted[owner.Service] {
+				counted[owner.Service] = true
+				if services[owner.Service] == nil {
+					services[owner.Service] = &Usage{}
+				}
+				services[owner.Service].add(size)
+			}
+			if entities[owner] == nil {
+				entities[owner] = &Usage{}
+			}
+			entities[owner].add(size)
+		}
+	}
+
+	for service, usage := range services {
+		report.Services = append(report.Services, ServiceUsage{Service: service, Usage: *usage})
+	}
+	sort.Slice(report.Services, func(i, j int) bool {
+		return report.Services[i].Service < report.Services[j].Service
+	})
+
+	for owner, usage := range entities {
+		report.Entities = append(report.Entities, EntityUsage{Owner: owner, Usage: *usage})
+	}
+	sort.Slice(report.Entities, func(i, j int) bool {
+		a, b := report.Entities[i], report.Entities[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.EntityID < b.EntityID
+	})
+	if len(report.Entities) > limit {
+		report.Entities = report.Entities[:limit]
+	}
+	return report, nil
+}
+
+func (u *Usage) add(size int64) {
+	u.Files++
+	u.Bytes += size
+}
@@ -0,0 +1,205 @@
+// Package imageproc checks uploaded images and renders the resized variants
+// served to clients. Re-encoding drops all metadata, so EXIF and GPS data
+// never reach the variants; the EXIF orientation is applied first so photos
+// keep their intended rotation.
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxUploadBytes bounds the size of an uploaded file.
+	MaxUploadBytes = 20 << 20
+	// maxPixels guards against decompression bombs: small files that decode
+	// to huge images.
+	maxPixels   = 50_000_000
+	jpegQuality = 82
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type (expected JPEG, PNG, GIF or WebP)")
+	ErrTooLarge        = errors.New("image is too large")
+)
+
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Size is a named variant whose longer side is at most MaxSide pixels.
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+// Sizes are rendered from largest to smallest; each is scaled down from the
+// previous one.
+var Sizes = []Size{
+	{Name: "large", MaxSide: 1600},
+	{Name: "medium", MaxSide: 800},
+	{Name: "thumbnail", MaxSide: 320},
+}
+
+// Variant is one encoded size of an image. WebP is nil when no WebP encoder
+// is available.
+type Variant struct {
+	Name        string
+	Width       int
+	Height      int
+	Ext         string
+	ContentType string
+	Data        []byte
+	WebP        []byte
+}
+
+// Process reads an upload, checks its type by sniffing the content rather
+// than trusting the file name or header, and renders every size in Sizes.
+func Process(r io.Reader) ([]Variant, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxUploadBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedTypes[contentType] {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	orientation := 1
+	if contentType == "image/jpeg" {
+		orientation = exifOrientation(data)
+	}
+	opaque := isOpaque(src)
+
+	variants := make([]Variant, 0, len(Sizes))
+	current := src
+	for _, size := range Sizes {
+		current = fit(current, size.MaxSide)
+		oriented := orient(current, orientation)
+
+		variant := Variant{
+			Name:   size.Name,
+			Width:  oriented.Bounds().Dx(),
+			Height: oriented.Bounds().Dy(),
+		}
+		var buf bytes.Buffer
+		if opaque {
+			variant.Ext, variant.ContentType = ".jpg", "image/jpeg"
+			err = jpeg.Encode(&buf, oriented, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			variant.Ext, variant.ContentType = ".png", "image/png"
+			err = png.Encode(&buf, oriented)
+		}
+		if err != nil {
+			return nil, err
+		}
+		variant.Data = buf.Bytes()
+
+		if variant.WebP, err = encodeWebP(oriented); err != nil {
+			log.Printf("Skipping WebP %s variant: %v", size.Name, err)
+		}
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// fit scales img down so its longer side is at most maxSide. Images that
+// already fit are returned unchanged.
+func fit(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = max(1, w*maxSide/h)
+		h = maxSide
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// orient applies an EXIF orientation (1-8) to img.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	// Orientations 5-8 swap width and height.
+	transposed := orientation >= 5
+	dw, dh := w, h
+	if transposed {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)))
+		}
+	}
+	return dst
+}
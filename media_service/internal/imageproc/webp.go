@@ -0,0 +1,51 @@
+package imageproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+var (
+	cwebpOnce sync.Once
+	cwebpPath string
+)
+
+// encodeWebP encodes img with the cwebp tool when it is installed. Go has no
+// WebP encoder in the standard library, so without cwebp only JPEG/PNG
+// variants are produced and nil is returned.
+func encodeWebP(img image.Image) ([]byte, error) {
+	cwebpOnce.Do(func() {
+		cwebpPath, _ = exec.LookPath("cwebp")
+	})
+	if cwebpPath == "" {
+		return nil, nil
+	}
+
+	in, err := os.CreateTemp("", "variant-*.png")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in.Name())
+	if err := png.Encode(in, img); err != nil {
+		in.Close()
+		return nil, err
+	}
+	if err := in.Close(); err != nil {
+		return nil, err
+	}
+
+	out := in.Name() + ".webp"
+	defer os.Remove(out)
+	var stderr bytes.Buffer
+	cmd := exec.Command(cwebpPath, "-quiet", "-q", "80", "-metadata", "none", in.Name(), "-o", out)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("cwebp failed: %w: %s", err, stderr.String())
+	}
+	return os.ReadFile(out)
+}
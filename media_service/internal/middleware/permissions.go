@@ -0,0 +1,9 @@
+package middleware
+
+// Media permissions granted by the auth service's roles. Uploading is open
+// to content editors; sweeping storage deletes files, so it and the usage
+// report are kept to admins who manage media.
+const (
+	PermMediaUpload = "media.upload"
+	PermMediaManage = "media.manage"
+)
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// MediaObject records a stored file. Key is the storage key, the SHA-256 of
+// the content plus its extension, so uploading the same file twice reuses
+// the row. QuarantinedAt is set while the garbage collector considers the
+// file unused; it is deleted once the grace period has passed.
+type MediaObject struct {
+	Key           string     `json:"key" gorm:"primaryKey"`
+	Size          int64      `json:"size"`
+	ContentType   string     `json:"content_type"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UploadedAt    time.Time  `json:"uploaded_at" gorm:"not null;default:now()"`
+	QuarantinedAt *time.Time `json:"quarantined_at" gorm:"index"`
+}
+
+// ImageVariant is one rendered size of an uploaded image. WebPURL is empty
+// when the service could not encode WebP.
+type ImageVariant struct {
+	URL     string `json:"url"`
+	WebPURL string `json:"webp_url,omitempty"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// ImageVariants maps variant names ("thumbnail", "medium", "large") to the
+// rendered files.
+type ImageVariants map[string]ImageVariant
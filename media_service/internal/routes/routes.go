@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"diplomaPorject/backend/media_service/internal/controllers"
+	"diplomaPorject/backend/media_service/internal/middleware"
+	"diplomaPorject/backend/shared/access"
+	"github.com/gorilla/mux"
+)
+
+func SetupRoutes() *mux.Router {
+	r := mux.NewRouter()
+
+	// Each admin route checks the permission it needs; AdminAuthMiddleware
+	// only establishes that the caller is an admin.
+	can := access.RequirePermission
+
+	admin := r.PathPrefix("/media").Subrouter()
+	admin.Use(access.AdminAuthMiddleware)
+	admin.HandleFunc("/images", can(middleware.PermMediaUpload, controllers.UploadImage)).Methods("POST")
+
+	storage := r.PathPrefix("/admin/media").Subrouter()
+	storage.Use(access.AdminAuthMiddleware)
+	storage.HandleFunc("/sweep", can(middleware.PermMediaManage, controllers.SweepStorage)).Methods("POST")
+	storage.HandleFunc("/usage", can(middleware.PermMediaManage, controllers.StorageUsage)).Methods("GET")
+
+	r.HandleFunc("/media/{key}", controllers.ServeMedia).Methods("GET", "HEAD")
+	r.PathPrefix("/uploads/").HandlerFunc(controllers.ServeLegacyUpload).Methods("GET", "HEAD")
+
+	return r
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// MediaPrefix is the URL path under which stored objects are served.
+	MediaPrefix = "/media/"
+	// LegacyPrefix is the URL path of files uploaded before the media
+	// service existed.
+	LegacyPrefix = "/uploads/"
+	// quarantineDirName is the directory inside LegacyDir that holds legacy
+	// files awaiting deletion. It is never served.
+	quarantineDirName = ".quarantine"
+)
+
+// LegacyDir holds the files the attraction and events services wrote to
+// disk before images moved to storage.
+var LegacyDir = "/app/uploads"
+
+func init() {
+	if dir := os.Getenv("LEGACY_UPLOAD_DIR"); dir != "" {
+		LegacyDir = dir
+	}
+}
+
+// QuarantineDir returns the directory legacy files are moved to before they
+// are deleted.
+func QuarantineDir() string {
+	return filepath.Join(LegacyDir, quarantineDirName)
+}
+
+// IsQuarantined reports whether a cleaned path relative to LegacyDir points
+// into the quarantine directory.
+func IsQuarantined(name string) bool {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "/")
+	return name == quarantineDirName || strings.HasPrefix(name, quarantineDirName+"/")
+}
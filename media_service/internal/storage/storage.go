@@ -0,0 +1,97 @@
+// Package storage keeps media files in a content-addressed store. A file's
+// key is the SHA-256 of its content plus its extension, so identical files
+// are stored once. The backend is chosen with STORAGE_BACKEND: "local" (the
+// default) or "s3" for any S3-compatible service such as MinIO.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// keyPattern matches the keys produced by Key.
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}\.[a-z0-9]+$`)
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Object is an open stored file. It can seek so that range requests can be
+// served from it.
+type Object interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+type Storage interface {
+	// Put stores data under key. Storing a key that already exists is a
+	// no-op, since equal keys mean equal content.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (Object, *ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Key returns the content address of data stored with the extension ext,
+// e.g. ".jpg".
+func Key(data []byte, ext string) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) + ext
+}
+
+// ValidKey reports whether key has the form produced by Key, which also
+// keeps it from escaping the storage root.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// Hash returns the content hash part of a key.
+func Hash(key string) string {
+	return key[:sha256.Size*2]
+}
+
+var active Storage
+
+// Setup opens the backend named by STORAGE_BACKEND.
+func Setup(ctx context.Context) error {
+	var err error
+	switch name := os.Getenv("STORAGE_BACKEND"); name {
+	case "", "local":
+		active, err = NewLocal(envOr("MEDIA_ROOT", "/app/media"))
+	case "s3":
+		active, err = NewS3(ctx, S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Bucket:    envOr("S3_BUCKET", "travelkz-media"),
+			Region:    os.Getenv("S3_REGION"),
+			UseSSL:    os.Getenv("S3_USE_SSL") == "true",
+		})
+	default:
+		return fmt.Errorf("unknown storage backend %q", name)
+	}
+	return err
+}
+
+// Active returns the configured backend.
+func Active() Storage {
+	return active
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -1,4 +1,4 @@
-package middleware
+package access
 
 import (
 	"context"
@@ -9,7 +9,8 @@ import (
 )
 
 type AdminResponse struct {
-	AdminID uint `json:"admin_id"`
+	AdminID     uint     `json:"admin_id"`
+	Permissions []string `json:"permissions"`
 }
 
 func AdminAuthMiddleware(next http.Handler) http.Handler {
@@ -57,6 +58,7 @@ func AdminAuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), "admin_id", adminResp.AdminID)
+		ctx = context.WithValue(ctx, "permissions", adminResp.Permissions)
 
 		r = r.WithContext(ctx)
 
@@ -0,0 +1,57 @@
+package access
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"os"
+)
+
+var ErrNotOwner = errors.New("only the admin who created this content or a moderator can change it")
+
+// ownerEditPolicy limits changes to content to the admin who created it and
+// admins allowed to edit any content. It is enabled with EDIT_POLICY=owner;
+// by default any admin may edit any content.
+var ownerEditPolicy = os.Getenv("EDIT_POLICY") == "owner"
+
+// AdminID returns the ID of the admin authenticated by AdminAuthMiddleware.
+func AdminID(r *http.Request) uint {
+	adminID, _ := r.Context().Value("admin_id").(uint)
+	return adminID
+}
+
+// MayEditAny reports whether the admin may edit content created by others,
+// as super-admins and moderators can.
+func MayEditAny(r *http.Request) bool {
+	return HasPermission(r, PermContentEditAny)
+}
+
+// MayEdit reports whether the calling admin may change content owned by
+// ownerID under the current policy.
+func MayEdit(r *http.Request, ownerID uint) bool {
+	return !ownerEditPolicy || MayEditAny(r) || ownerID == AdminID(r)
+}
+
+// OwnerOnly guards a handler of an "/{id}" route that changes a record,
+// enforcing the edit policy before the handler runs. owner returns the ID
+// of the admin who created the record, or an error that is reported as
+// not found.
+func OwnerOnly(owner func(id string) (uint, error), next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ownerEditPolicy || MayEditAny(r) {
+			next(w, r)
+			return
+		}
+
+		ownerID, err := owner(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if !MayEdit(r, ownerID) {
+			http.Error(w, ErrNotOwner.Error(), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
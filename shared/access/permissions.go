@@ -0,0 +1,36 @@
+// Package access authenticates admins through the auth service and checks
+// the permissions their roles grant.
+package access
+
+import "net/http"
+
+// Permissions checked by more than one service. Each service declares its
+// own permissions in its middleware package.
+const (
+	PermAuditRead      = "audit.read"
+	PermContentEditAny = "content.edit_any" // Overrides the owner-only edit policy
+)
+
+// HasPermission reports whether the admin authenticated by
+// AdminAuthMiddleware holds permission.
+func HasPermission(r *http.Request, permission string) bool {
+	permissions, _ := r.Context().Value("permissions").([]string)
+	for _, granted := range permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission guards a handler behind AdminAuthMiddleware that only
+// admins holding permission may call.
+func RequirePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !HasPermission(r, permission) {
+			http.Error(w, "Forbidden - missing permission "+permission, http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,165 @@
+// Package audit records admin changes together with a diff of the fields
+// that changed.
+package audit
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"time"
+)
+
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionPublish   = "publish"
+	ActionUnpublish = "unpublish"
+	ActionSchedule  = "schedule"
+	ActionCancel    = "cancel"
+)
+
+// SystemAdmin is the admin recorded for changes the services make on their
+// own, such as applying a scheduled publish time.
+const SystemAdmin uint = 0
+
+// FieldChange is the value of a field before and after a change. Before is
+// null for created entities and After for deleted ones.
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Changes maps JSON field names to their change.
+type Changes map[string]FieldChange
+
+func (c Changes) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+func (c *Changes) Scan(value interface{}) error {
+	var data []byte
+	switch raw := value.(type) {
+	case []byte:
+		data = raw
+	case string:
+		data = []byte(raw)
+	case nil:
+		*c = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into audit.Changes", value)
+	}
+	return json.Unmarshal(data, c)
+}
+
+// Entry records who changed what. The services share a database, so each
+// keeps its entries in its own table through a model embedding Entry.
+type Entry struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+	AdminID    uint      `json:"admin_id" gorm:"index"`
+	Action     string    `json:"action" gorm:"index"`
+	EntityType string    `json:"entity_type" gorm:"index:,composite:entity"`
+	EntityID   uint      `json:"entity_id" gorm:"index:,composite:entity"`
+	Changes    Changes   `json:"changes" gorm:"type:jsonb"`
+}
+
+// ignoredFields change on every write or belong to other records, and would
+// only add noise to a diff.
+var ignoredFields = map[string]bool{
+	"CreatedAt":      true,
+	"UpdatedAt":      true,
+	"DeletedAt":      true,
+	"image_variants": true,
+	"images":         true,
+}
+
+// Log records the changes to one type of entity.
+type Log struct {
+	// Table holds the entries, e.g. "event_audit_entries".
+	Table      string
+	EntityType string
+	// Ignore lists fields, in addition to the common ones, that are left
+	// out of diffs.
+	Ignore []string
+}
+
+// Snapshot captures the JSON fields of v, so that a later change to v does
+// not alter the "before" side of a diff.
+func Snapshot(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (l Log) ignored(name string) bool {
+	if ignoredFields[name] {
+		return true
+	}
+	for _, field := range l.Ignore {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the fields that differ between before and after. A nil
+// before describes a create and a nil after a delete.
+func (l Log) Diff(before, after interface{}) (Changes, error) {
+	old, err := Snapshot(before)
+	if err != nil {
+		return nil, err
+	}
+	current, err := Snapshot(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(Changes)
+	for name, value := range old {
+		if !l.ignored(name) && !reflect.DeepEqual(value, current[name]) {
+			changes[name] = FieldChange{Before: value, After: current[name]}
+		}
+	}
+	for name, value := range current {
+		if _, seen := old[name]; !seen && !l.ignored(name) && value != nil {
+			changes[name] = FieldChange{Before: nil, After: value}
+		}
+	}
+	return changes, nil
+}
+
+// Record writes an audit entry in tx, so it is kept only if the change is.
+// An update that changed nothing is not recorded.
+func (l Log) Record(tx *gorm.DB, adminID uint, action string, entityID uint, before, after interface{}) error {
+	changes, err := l.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 && before != nil && after != nil {
+		return nil
+	}
+	return tx.Table(l.Table).Create(&Entry{
+		AdminID:    adminID,
+		Action:     action,
+		EntityType: l.EntityType,
+		EntityID:   entityID,
+		Changes:    changes,
+	}).Error
+}
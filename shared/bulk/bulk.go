@@ -0,0 +1,290 @@
+// Package bulk reads and writes the CSV and JSON documents used by admin
+// imports and exports. Both formats carry the same named columns, so an
+// export can be edited and imported again.
+package bulk
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var ErrUnknownFormat = errors.New("format must be csv or json")
+
+// Row is one imported record. Number counts data rows from 1, not including
+// the CSV header.
+type Row struct {
+	Number int
+	Fields map[string]string
+}
+
+func (r Row) Get(name string) string {
+	return r.Fields[name]
+}
+
+// ID returns the row's "id" column, or 0 when the row has none and so
+// describes a new record.
+func (r Row) ID() (uint, error) {
+	value := r.Get("id")
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", value)
+	}
+	return uint(id), nil
+}
+
+// DetectFormat uses the "format" query parameter, falling back to the
+// request's Content-Type.
+func DetectFormat(r *http.Request) (string, error) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		if format != FormatCSV && format != FormatJSON {
+			return "", ErrUnknownFormat
+		}
+		return format, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return FormatCSV, nil
+	case "application/json":
+		return FormatJSON, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ReadRows parses a document, rejecting columns that are not in columns and
+// documents with more than maxRows rows.
+func ReadRows(body io.Reader, format string, columns []string, maxRows int) ([]Row, error) {
+	allowed := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		allowed[column] = true
+	}
+
+	var rows []Row
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(body)
+	case FormatJSON:
+		rows, err = readJSON(body)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("document has no rows")
+	}
+	if len(rows) > maxRows {
+		return nil, fmt.Errorf("document has %d rows, the limit is %d", len(rows), maxRows)
+	}
+	for _, row := range rows {
+		for name := range row.Fields {
+			if !allowed[name] {
+				return nil, fmt.Errorf("unknown column %q", name)
+			}
+		}
+	}
+	return rows, nil
+}
+
+func readCSV(body io.Reader) ([]Row, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		row := Row{Number: len(rows) + 1, Fields: make(map[string]string, len(header))}
+		for i, name := range header {
+			row.Fields[name] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readJSON(body io.Reader) ([]Row, error) {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+
+	var records []map[string]interface{}
+	if err := decoder.Decode(&records); err != nil {
+		return nil, errors.New("invalid JSON: expected an array of objects")
+	}
+
+	rows := make([]Row, 0, len(records))
+	for i, record := range records {
+		row := Row{Number: i + 1, Fields: make(map[string]string, len(record))}
+		for name, value := range record {
+			text, err := formatValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %s: %w", row.Number, name, err)
+			}
+			row.Fields[strings.ToLower(name)] = text
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ReadObject parses a single JSON object, such as the body of an update
+// request, into fields. Only the fields present in the object are returned.
+func ReadObject(body io.Reader, columns []string) (map[string]string, error) {
+	allowed := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		allowed[column] = true
+	}
+
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return nil, errors.New("invalid JSON: expected an object")
+	}
+
+	fields := make(map[string]string, len(object))
+	for name, value := range object {
+		name = strings.ToLower(name)
+		if !allowed[name] {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		text, err := formatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		fields[name] = text
+	}
+	return fields, nil
+}
+
+// RecordFields turns an export record into fields keyed by column, the same
+// text an import of that record would carry.
+func RecordFields(columns []string, record []interface{}) (map[string]string, error) {
+	fields := make(map[string]string, len(columns))
+	for i, column := range columns {
+		text, err := formatValue(record[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", column, err)
+		}
+		fields[column] = text
+	}
+	return fields, nil
+}
+
+// formatValue turns a JSON or export value into its CSV cell text. Nested
+// objects and arrays are kept as JSON.
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case *float64:
+		if v == nil {
+			return "", nil
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return "", nil
+	}
+	return string(data), nil
+}
+
+// Write encodes records in the given format. records hold one value per
+// column; nil pointers are written as empty cells or JSON null.
+func Write(w http.ResponseWriter, format, filename string, columns []string, records [][]interface{}) error {
+	switch format {
+	case FormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	case FormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+	default:
+		return ErrUnknownFormat
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+"."+format))
+
+	if format == FormatJSON {
+		objects := make([]map[string]interface{}, 0, len(records))
+		for _, record := range records {
+			object := make(map[string]interface{}, len(columns))
+			for i, column := range columns {
+				object[column] = record[i]
+			}
+			objects = append(objects, object)
+		}
+		return json.NewEncoder(w).Encode(objects)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		cells := make([]string, len(record))
+		for i, value := range record {
+			text, err := formatValue(value)
+			if err != nil {
+				return err
+			}
+			cells[i] = text
+		}
+		if err := writer.Write(cells); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,154 @@
+package bulk
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testColumns = []string{"id", "title", "price", "published"}
+
+func TestReadRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		body    string
+		want    []map[string]string
+		wantErr string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			body:   "id,title,price\n1,Concert,2500\n,\"Opera, gala\",\n",
+			want: []map[string]string{
+				{"id": "1", "title": "Concert", "price": "2500"},
+				{"id": "", "title": "Opera, gala", "price": ""},
+			},
+		},
+		{
+			name:   "csv header is case-insensitive and may carry a BOM",
+			format: FormatCSV,
+			body:   "\ufeffID, Title \n 7 ,  Museum night  \n",
+			want:   []map[string]string{{"id": "7", "title": "Museum night"}},
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			body:   `[{"id": 3, "Title": " Jazz ", "price": 1500.5, "published": true}, {"title": null}]`,
+			want: []map[string]string{
+				{"id": "3", "title": "Jazz", "price": "1500.5", "published": "true"},
+				{"title": ""},
+			},
+		},
+		{name: "csv without rows", format: FormatCSV, body: "id,title\n", wantErr: "document has no rows"},
+		{name: "empty csv", format: FormatCSV, body: "", wantErr: "document has no rows"},
+		{name: "empty json array", format: FormatJSON, body: "[]", wantErr: "document has no rows"},
+		{name: "unknown csv column", format: FormatCSV, body: "id,owner\n1,2\n", wantErr: `unknown column "owner"`},
+		{name: "unknown json field", format: FormatJSON, body: `[{"owner": 2}]`, wantErr: `unknown column "owner"`},
+		{name: "ragged csv", format: FormatCSV, body: "id,title\n1\n", wantErr: "invalid CSV"},
+		{name: "json object", format: FormatJSON, body: `{"id": 1}`, wantErr: "invalid JSON"},
+		{name: "too many rows", format: FormatCSV, body: "id\n1\n2\n3\n4\n", wantErr: "document has 4 rows, the limit is 3"},
+		{name: "unknown format", format: "xml", body: "<events/>", wantErr: ErrUnknownFormat.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ReadRows(strings.NewReader(tt.body), tt.format, testColumns, 3)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadRows: %v", err)
+			}
+			if len(rows) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(tt.want))
+			}
+			for i, row := range rows {
+				if row.Number != i+1 {
+					t.Errorf("row %d numbered %d", i+1, row.Number)
+				}
+				if !reflect.DeepEqual(row.Fields, tt.want[i]) {
+					t.Errorf("row %d = %v, want %v", i+1, row.Fields, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRowID(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    uint
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"42", 42, false},
+		{"-1", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Row{Fields: map[string]string{"id": tt.value}}.ID()
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ID() = %d, %v; want %d, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		query, contentType string
+		want               string
+		wantErr            bool
+	}{
+		{query: "format=csv", want: FormatCSV},
+		{query: "format=JSON", contentType: "text/csv", want: FormatJSON},
+		{contentType: "text/csv; charset=utf-8", want: FormatCSV},
+		{contentType: "application/json", want: FormatJSON},
+		{query: "format=xml", wantErr: true},
+		{contentType: "text/plain", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+" "+tt.contentType, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/import?"+tt.query, nil)
+			r.Header.Set("Content-Type", tt.contentType)
+			got, err := DetectFormat(r)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("DetectFormat = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	price := 2500.0
+	records := [][]interface{}{
+		{uint(1), "Concert, live", &price, true},
+		{uint(2), "Opera", (*float64)(nil), false},
+	}
+	for _, format := range []string{FormatCSV, FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := Write(w, format, "events", testColumns, records); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			rows, err := ReadRows(w.Body, format, testColumns, 10)
+			if err != nil {
+				t.Fatalf("ReadRows: %v", err)
+			}
+			for i, record := range records {
+				want, err := RecordFields(testColumns, record)
+				if err != nil {
+					t.Fatalf("RecordFields: %v", err)
+				}
+				if !reflect.DeepEqual(rows[i].Fields, want) {
+					t.Errorf("row %d = %v, want %v", i+1, rows[i].Fields, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package bulk
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// RowError is a problem found with one row of an import.
+type RowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// Report describes what an import did, or would do in a dry run.
+type Report struct {
+	DryRun  bool       `json:"dry_run"`
+	Total   int        `json:"total"`
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Errors  []RowError `json:"errors"`
+}
+
+func NewReport(dryRun bool, total int) *Report {
+	return &Report{DryRun: dryRun, Total: total, Errors: []RowError{}}
+}
+
+func WriteReport(w http.ResponseWriter, status int, report *Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// ReadUpload returns the uploaded document, taken from a multipart "file"
+// field or the raw request body, along with its format.
+func ReadUpload(r *http.Request) (io.Reader, string, error) {
+	if file, header, err := r.FormFile("file"); err == nil {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			if strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+				format = FormatCSV
+			} else {
+				format = FormatJSON
+			}
+		}
+		if format != FormatCSV && format != FormatJSON {
+			return nil, "", ErrUnknownFormat
+		}
+		return file, format, nil
+	}
+
+	format, err := DetectFormat(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return r.Body, format, nil
+}
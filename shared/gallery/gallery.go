@@ -0,0 +1,256 @@
+// Package gallery keeps ordered image galleries with captions and a cover
+// image for records such as events and attractions.
+package gallery
+
+import (
+	"diplomaPorject/backend/shared/media"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+var (
+	ErrImageNotFound = errors.New("image not found")
+	ErrImageOrder    = errors.New("image_ids must list every image of the gallery exactly once")
+)
+
+// Image holds the fields of a gallery image. A service's image model embeds
+// it next to the column that links the image to its owner.
+type Image struct {
+	gorm.Model
+	Position int                 `json:"position"`
+	URL      string              `json:"url"`
+	Variants media.ImageVariants `json:"variants" gorm:"type:jsonb"`
+	Caption  string              `json:"caption"`
+	AltText  string              `json:"alt_text"`
+	IsCover  bool                `json:"is_cover"`
+}
+
+// GalleryImage gives generic code access to the Image embedded in a model.
+func (i *Image) GalleryImage() *Image {
+	return i
+}
+
+// Model is a pointer to a service's image model.
+type Model[T any] interface {
+	*T
+	GalleryImage() *Image
+}
+
+// Owner is a record with a gallery. Its cover image is mirrored into the
+// owner's image_url and image_variants columns, so that clients reading a
+// single image keep working.
+type Owner interface {
+	GalleryID() uint
+	SetCoverImage(url string, variants media.ImageVariants)
+}
+
+// Upload is an uploaded image waiting to be added to a gallery.
+type Upload struct {
+	Variants media.ImageVariants
+	Caption  string
+	AltText  string
+}
+
+// UploadForm uploads every "images" file of a parsed multipart form.
+// Captions and alt texts are matched to files by order through repeated
+// "caption" and "alt_text" fields.
+func UploadForm(r *http.Request) ([]Upload, error) {
+	files := r.MultipartForm.File["images"]
+	captions := r.MultipartForm.Value["caption"]
+	altTexts := r.MultipartForm.Value["alt_text"]
+
+	uploads := make([]Upload, 0, len(files))
+	for i, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		variants, err := media.UploadImage(r, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		upload := Upload{Variants: variants}
+		if i < len(captions) {
+			upload.Caption = captions[i]
+		}
+		if i < len(altTexts) {
+			upload.AltText = altTexts[i]
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
+
+// Ordered is the Preload condition that returns images in gallery order.
+func Ordered(tx *gorm.DB) *gorm.DB {
+	return tx.Order("position ASC, id ASC")
+}
+
+// Gallery stores the images of one kind of owner in the table of T.
+type Gallery[T any, P Model[T]] struct {
+	// OwnerColumn links an image to its owner, e.g. "event_id".
+	OwnerColumn string
+	// New returns an image of the owner with the given ID.
+	New func(ownerID uint, image Image) T
+}
+
+func (g Gallery[T, P]) of(tx *gorm.DB, ownerID uint) *gorm.DB {
+	return tx.Where(g.OwnerColumn+" = ?", ownerID)
+}
+
+// Add appends images to the end of the gallery. The first image of an empty
+// gallery becomes the cover.
+func (g Gallery[T, P]) Add(tx *gorm.DB, owner Owner, uploads []Upload) ([]T, error) {
+	var count int64
+	if err := g.of(tx.Model(new(T)), owner.GalleryID()).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	var lastPosition int
+	if err := g.of(tx.Model(new(T)), owner.GalleryID()).
+		Select("COALESCE(MAX(position), 0)").Scan(&lastPosition).Error; err != nil {
+		return nil, err
+	}
+
+	images := make([]T, 0, len(uploads))
+	for i, upload := range uploads {
+		image := g.New(owner.GalleryID(), Image{
+			Position: lastPosition + i + 1,
+			URL:      upload.Variants["large"].URL,
+			Variants: upload.Variants,
+			Caption:  upload.Caption,
+			AltText:  upload.AltText,
+		})
+		if err := tx.Create(&image).Error; err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+
+	if count == 0 && len(images) > 0 {
+		if err := g.SetCover(tx, owner, &images[0]); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// SetCover makes image the cover, or clears the cover when image is nil.
+func (g Gallery[T, P]) SetCover(tx *gorm.DB, owner Owner, image P) error {
+	if err := g.of(tx.Model(new(T)), owner.GalleryID()).Update("is_cover", false).Error; err != nil {
+		return err
+	}
+
+	owner.SetCoverImage("", nil)
+	if image != nil {
+		if err := tx.Model(image).Update("is_cover", true).Error; err != nil {
+			return err
+		}
+		cover := image.GalleryImage()
+		cover.IsCover = true
+		owner.SetCoverImage(cover.URL, cover.Variants)
+	}
+	return tx.Model(owner).Select("image_url", "image_variants").Updates(owner).Error
+}
+
+// ReplaceCover swaps the files of the cover image for newly uploaded ones,
+// keeping its place, caption and alt text. A gallery without a cover gets
+// the image added as its cover.
+func (g Gallery[T, P]) ReplaceCover(tx *gorm.DB, owner Owner, variants media.ImageVariants) error {
+	var cover T
+	err := g.of(tx, owner.GalleryID()).Where("is_cover").First(&cover).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		images, err := g.Add(tx, owner, []Upload{{Variants: variants}})
+		if err != nil {
+			return err
+		}
+		return g.SetCover(tx, owner, &images[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	image := P(&cover).GalleryImage()
+	image.URL = variants["large"].URL
+	image.Variants = variants
+	if err := tx.Model(&cover).Select("url", "variants").Updates(&cover).Error; err != nil {
+		return err
+	}
+	return g.SetCover(tx, owner, &cover)
+}
+
+// List returns the images of an owner in gallery order.
+func (g Gallery[T, P]) List(tx *gorm.DB, ownerID uint) ([]T, error) {
+	images := []T{}
+	err := g.of(Ordered(tx), ownerID).Find(&images).Error
+	return images, err
+}
+
+// Find returns one image of an owner.
+func (g Gallery[T, P]) Find(tx *gorm.DB, ownerID uint, imageID string) (P, error) {
+	var image T
+	err := g.of(tx, ownerID).First(&image, imageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// Reorder renumbers the gallery in the order of imageIDs, which must list
+// every image of the owner once.
+func (g Gallery[T, P]) Reorder(tx *gorm.DB, ownerID uint, imageIDs []uint) error {
+	var ids []uint
+	if err := g.of(tx.Model(new(T)), ownerID).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	current := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		current[id] = true
+	}
+	if len(imageIDs) != len(current) {
+		return ErrImageOrder
+	}
+	for _, id := range imageIDs {
+		if !current[id] {
+			return ErrImageOrder
+		}
+		delete(current, id)
+	}
+
+	for i, id := range imageIDs {
+		if err := tx.Model(new(T)).Where("id = ?", id).Update("position", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes an image. When the cover is deleted, the next image in
+// order takes its place.
+func (g Gallery[T, P]) Delete(tx *gorm.DB, owner Owner, imageID string) error {
+	image, err := g.Find(tx, owner.GalleryID(), imageID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Delete(image).Error; err != nil {
+		return err
+	}
+	if !image.GalleryImage().IsCover {
+		return nil
+	}
+
+	var next T
+	err = g.of(Ordered(tx), owner.GalleryID()).First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return g.SetCover(tx, owner, nil)
+	}
+	if err != nil {
+		return err
+	}
+	return g.SetCover(tx, owner, &next)
+}
@@ -0,0 +1,208 @@
+package gallery
+
+import (
+	"diplomaPorject/backend/shared/media"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+)
+
+// Handlers serves the admin routes of a gallery. The routes carry the
+// owner's ID in the "id" path variable and an image's ID in "imageId".
+type Handlers[T any, P Model[T]] struct {
+	Gallery Gallery[T, P]
+	// DB returns the service's database connection.
+	DB func() *gorm.DB
+	// Load returns the owner with the given ID, or NotFound. Handlers that
+	// change the gallery pass a tx that locks the owner's row.
+	Load     func(tx *gorm.DB, id string) (Owner, error)
+	NotFound error
+}
+
+func (h Handlers[T, P]) lock(tx *gorm.DB, id string) (Owner, error) {
+	return h.Load(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+func (h Handlers[T, P]) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, h.NotFound), errors.Is(err, ErrImageNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrImageOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Gallery update failed: %v", err)
+		http.Error(w, "Failed to update gallery", http.StatusInternalServerError)
+	}
+}
+
+func (h Handlers[T, P]) writeGallery(w http.ResponseWriter, ownerID uint) {
+	images, err := h.Gallery.List(h.DB(), ownerID)
+	if err != nil {
+		http.Error(w, "Failed to fetch images", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(images)
+}
+
+func (h Handlers[T, P]) List(w http.ResponseWriter, r *http.Request) {
+	owner, err := h.Load(h.DB(), mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeGallery(w, owner.GalleryID())
+}
+
+// Add uploads one or more "images" files, see UploadForm. With cover=true
+// the first new image becomes the cover.
+func (h Handlers[T, P]) Add(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	uploads, err := UploadForm(r)
+	if err != nil {
+		media.WriteUploadError(w, err)
+		return
+	}
+	if len(uploads) == 0 {
+		http.Error(w, "No image file provided", http.StatusBadRequest)
+		return
+	}
+
+	var images []T
+	err = h.DB().Transaction(func(tx *gorm.DB) error {
+		owner, err := h.lock(tx, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+		images, err = h.Gallery.Add(tx, owner, uploads)
+		if err != nil {
+			return err
+		}
+		if first := P(&images[0]); r.FormValue("cover") == "true" && !first.GalleryImage().IsCover {
+			return h.Gallery.SetCover(tx, owner, first)
+		}
+		return nil
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(images)
+}
+
+// UpdateImage changes the caption and alt text of an image.
+func (h Handlers[T, P]) UpdateImage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		Caption string `json:"caption"`
+		AltText string `json:"alt_text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	owner, err := h.Load(h.DB(), vars["id"])
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	image, err := h.Gallery.Find(h.DB(), owner.GalleryID(), vars["imageId"])
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	image.GalleryImage().Caption = req.Caption
+	image.GalleryImage().AltText = req.AltText
+	if err := h.DB().Model(image).Select("caption", "alt_text").Updates(image).Error; err != nil {
+		http.Error(w, "Failed to update image", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(image)
+}
+
+// Reorder takes every image ID of the gallery in the new order.
+func (h Handlers[T, P]) Reorder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ImageIDs []uint `json:"image_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var owner Owner
+	err := h.DB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		owner, err = h.lock(tx, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+		return h.Gallery.Reorder(tx, owner.GalleryID(), req.ImageIDs)
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeGallery(w, owner.GalleryID())
+}
+
+func (h Handlers[T, P]) SetCover(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var owner Owner
+	err := h.DB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		owner, err = h.lock(tx, vars["id"])
+		if err != nil {
+			return err
+		}
+		image, err := h.Gallery.Find(tx, owner.GalleryID(), vars["imageId"])
+		if err != nil {
+			return err
+		}
+		return h.Gallery.SetCover(tx, owner, image)
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeGallery(w, owner.GalleryID())
+}
+
+// DeleteImage removes an image. When the cover is deleted, the next image in
+// order takes its place.
+func (h Handlers[T, P]) DeleteImage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	err := h.DB().Transaction(func(tx *gorm.DB) error {
+		owner, err := h.lock(tx, vars["id"])
+		if err != nil {
+			return err
+		}
+		return h.Gallery.Delete(tx, owner, vars["imageId"])
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,82 @@
+// Package geo provides distance and bounding-box helpers for "near me"
+// searches on latitude/longitude pairs.
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+const earthRadiusKm = 6371.0
+
+var (
+	ErrInvalidLatitude  = errors.New("latitude must be between -90 and 90")
+	ErrInvalidLongitude = errors.New("longitude must be between -180 and 180")
+	ErrIncomplete       = errors.New("latitude and longitude must be set together")
+)
+
+// BoundingBox is a latitude/longitude rectangle that contains every point
+// within a radius of its center. It is used as a cheap, indexable prefilter
+// before computing exact distances.
+type BoundingBox struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+func ValidateCoordinates(lat, lng float64) error {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return ErrInvalidLatitude
+	}
+	if math.IsNaN(lng) || lng < -180 || lng > 180 {
+		return ErrInvalidLongitude
+	}
+	return nil
+}
+
+// ValidateOptional accepts either both coordinates or neither.
+func ValidateOptional(lat, lng *float64) error {
+	if lat == nil && lng == nil {
+		return nil
+	}
+	if lat == nil || lng == nil {
+		return ErrIncomplete
+	}
+	return ValidateCoordinates(*lat, *lng)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// DistanceKm returns the great-circle distance between two points using the
+// haversine formula.
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLng := radians(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BoundingBoxAround returns the box enclosing a circle of radiusKm. Near the
+// poles or across the antimeridian the longitude range is widened to the full
+// circle rather than split in two.
+func BoundingBoxAround(lat, lng, radiusKm float64) BoundingBox {
+	angular := radiusKm / earthRadiusKm * 180 / math.Pi
+
+	box := BoundingBox{
+		MinLat: math.Max(lat-angular, -90),
+		MaxLat: math.Min(lat+angular, 90),
+		MinLng: -180,
+		MaxLng: 180,
+	}
+
+	if box.MinLat > -90 && box.MaxLat < 90 {
+		deltaLng := math.Asin(math.Min(1, math.Sin(radians(angular))/math.Cos(radians(lat)))) * 180 / math.Pi
+		if lng-deltaLng >= -180 && lng+deltaLng <= 180 {
+			box.MinLng = lng - deltaLng
+			box.MaxLng = lng + deltaLng
+		}
+	}
+	return box
+}
@@ -0,0 +1,162 @@
+package geo
+
+import (
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBoundingBoxAround(t *testing.T) {
+	tests := []struct {
+		name             string
+		lat, lng, radius float64
+		fullLongitude    bool
+	}{
+		{"almaty", 43.238, 76.945, 10, false},
+		{"equator", 0, 0, 50, false},
+		{"southern hemisphere", -33.87, 151.21, 25, false},
+		{"near the antimeridian", 65, 179.9, 20, true},
+		{"west of the antimeridian", -16.5, -179.95, 15, true},
+		{"north pole", 89.99, 10, 5, true},
+		{"south pole", -89.99, -40, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := BoundingBoxAround(tt.lat, tt.lng, tt.radius)
+			if box.MinLat > tt.lat || box.MaxLat < tt.lat || box.MinLng > tt.lng || box.MaxLng < tt.lng {
+				t.Fatalf("box %+v does not contain its center", box)
+			}
+			if box.MinLat < -90 || box.MaxLat > 90 || box.MinLng < -180 || box.MaxLng > 180 {
+				t.Fatalf("box %+v leaves the globe", box)
+			}
+			full := box.MinLng == -180 && box.MaxLng == 180
+			if full != tt.fullLongitude {
+				t.Fatalf("full longitude range = %v, want %v (box %+v)", full, tt.fullLongitude, box)
+			}
+
+			// Points radius away due north, south, east and west lie on or
+			// inside the box.
+			corners := [][2]float64{{box.MaxLat, tt.lng}, {box.MinLat, tt.lng}}
+			if !full {
+				corners = append(corners, [2]float64{tt.lat, box.MaxLng}, [2]float64{tt.lat, box.MinLng})
+			}
+			for _, p := range corners {
+				if d := DistanceKm(tt.lat, tt.lng, p[0], p[1]); d < tt.radius-0.01 && p[0] != 90 && p[0] != -90 {
+					t.Errorf("edge %v is only %.3f km from the center, want at least %.0f", p, d, tt.radius)
+				}
+			}
+		})
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 43.238, 76.945, 43.238, 76.945, 0},
+		{"one degree of latitude", 0, 0, 1, 0, 111.195},
+		{"quarter of the equator", 0, 0, 0, 90, 10007.543},
+		{"pole to pole", 90, 0, -90, 0, 20015.087},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111.195},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DistanceKm(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("DistanceKm = %.3f, want %.3f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOptional(t *testing.T) {
+	lat, lng, bad := 43.2, 76.9, 200.0
+	tests := []struct {
+		name     string
+		lat, lng *float64
+		want     error
+	}{
+		{"neither", nil, nil, nil},
+		{"both", &lat, &lng, nil},
+		{"latitude only", &lat, nil, ErrIncomplete},
+		{"longitude only", nil, &lng, ErrIncomplete},
+		{"latitude out of range", &bad, &lng, ErrInvalidLatitude},
+		{"longitude out of range", &lat, &bad, ErrInvalidLongitude},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateOptional(tt.lat, tt.lng); err != tt.want {
+				t.Errorf("ValidateOptional = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNearbyQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    NearbyQuery
+		wantErr bool
+	}{
+		{query: "lat=43.2&lng=76.9", want: NearbyQuery{Lat: 43.2, Lng: 76.9, RadiusKm: DefaultRadiusKm, Limit: DefaultLimit}},
+		{query: "lat=43.2&lng=76.9&radius_km=2.5&limit=5", want: NearbyQuery{Lat: 43.2, Lng: 76.9, RadiusKm: 2.5, Limit: 5}},
+		{query: "lat=43.2&lng=76.9&radius_km=200&limit=100", want: NearbyQuery{Lat: 43.2, Lng: 76.9, RadiusKm: 200, Limit: 100}},
+		{query: "lng=76.9", wantErr: true},
+		{query: "lat=43.2", wantErr: true},
+		{query: "lat=north&lng=76.9", wantErr: true},
+		{query: "lat=91&lng=76.9", wantErr: true},
+		{query: "lat=43.2&lng=-181", wantErr: true},
+		{query: "lat=43.2&lng=76.9&radius_km=0", wantErr: true},
+		{query: "lat=43.2&lng=76.9&radius_km=201", wantErr: true},
+		{query: "lat=43.2&lng=76.9&limit=0", wantErr: true},
+		{query: "lat=43.2&lng=76.9&limit=101", wantErr: true},
+		{query: "lat=43.2&lng=76.9&limit=ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := ParseNearbyQuery(httptest.NewRequest("GET", "/nearby?"+tt.query, nil))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseNearbyQuery = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseNearbyQuery: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("ParseNearbyQuery = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCoordinateFields(t *testing.T) {
+	tests := []struct {
+		name                string
+		latitude, longitude string
+		wantSet             bool
+		wantErr             error
+	}{
+		{"empty", "", "", false, nil},
+		{"both", "43.2", "76.9", true, nil},
+		{"latitude only", "43.2", "", false, ErrIncomplete},
+		{"malformed latitude", "x", "76.9", false, ErrInvalidLatitude},
+		{"malformed longitude", "43.2", "y", false, ErrInvalidLongitude},
+		{"out of range", "43.2", "190", false, ErrInvalidLongitude},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]string{"latitude": tt.latitude, "longitude": tt.longitude}
+			lat, lng, err := ParseCoordinateFields(func(name string) string { return fields[name] })
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if set := lat != nil && lng != nil; set != tt.wantSet {
+				t.Errorf("coordinates set = %v, want %v", set, tt.wantSet)
+			}
+		})
+	}
+}
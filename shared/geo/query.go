@@ -0,0 +1,84 @@
+package geo
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultRadiusKm = 10.0
+	MaxRadiusKm     = 200.0
+	DefaultLimit    = 20
+	MaxLimit        = 100
+)
+
+// NearbyQuery is a "near me" search: the closest Limit places within
+// RadiusKm of Lat, Lng.
+type NearbyQuery struct {
+	Lat, Lng float64
+	RadiusKm float64
+	Limit    int
+}
+
+// ParseNearbyQuery reads the lat, lng, radius_km and limit query
+// parameters. lat and lng are required.
+func ParseNearbyQuery(r *http.Request) (*NearbyQuery, error) {
+	q := r.URL.Query()
+	lat, err := strconv.ParseFloat(q.Get("lat"), 64)
+	if err != nil {
+		return nil, errors.New("lat is required and must be a number")
+	}
+	lng, err := strconv.ParseFloat(q.Get("lng"), 64)
+	if err != nil {
+		return nil, errors.New("lng is required and must be a number")
+	}
+	if err := ValidateCoordinates(lat, lng); err != nil {
+		return nil, err
+	}
+
+	query := &NearbyQuery{Lat: lat, Lng: lng, RadiusKm: DefaultRadiusKm, Limit: DefaultLimit}
+	if value := q.Get("radius_km"); value != "" {
+		radius, err := strconv.ParseFloat(value, 64)
+		if err != nil || radius <= 0 || radius > MaxRadiusKm {
+			return nil, errors.New("radius_km must be a number between 0 and 200")
+		}
+		query.RadiusKm = radius
+	}
+	if value := q.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return nil, errors.New("limit must be between 1 and 100")
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+func parseOptionalFloat(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ParseCoordinateFields reads the optional latitude and longitude fields of
+// a form, update or import row.
+func ParseCoordinateFields(value func(string) string) (*float64, *float64, error) {
+	latitude, err := parseOptionalFloat(value("latitude"))
+	if err != nil {
+		return nil, nil, ErrInvalidLatitude
+	}
+	longitude, err := parseOptionalFloat(value("longitude"))
+	if err != nil {
+		return nil, nil, ErrInvalidLongitude
+	}
+	if err := ValidateOptional(latitude, longitude); err != nil {
+		return nil, nil, err
+	}
+	return latitude, longitude, nil
+}
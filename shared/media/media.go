@@ -0,0 +1,94 @@
+// Package media is a client for the media service, which processes and
+// stores uploaded images.
+package media
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	serviceURL = "http://media-service:8086"
+	client     = &http.Client{Timeout: 60 * time.Second}
+)
+
+func init() {
+	if url := os.Getenv("MEDIA_SERVICE_URL"); url != "" {
+		serviceURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// Error is a rejection reported by the media service, such as an
+// unsupported or oversized image.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// UploadImage sends an image to the media service, which checks it, renders
+// the variants and stores them, and returns the URLs of the variants. The
+// raw upload, with its EXIF metadata, is never stored. The cookie of r is
+// forwarded so the media service can authorize the admin.
+func UploadImage(r *http.Request, file io.Reader) (ImageVariants, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "upload")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", serviceURL+"/media/images", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Cookie", r.Header.Get("Cookie"))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("media service unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &Error{Status: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	var variants ImageVariants
+	if err := json.NewDecoder(resp.Body).Decode(&variants); err != nil {
+		return nil, fmt.Errorf("invalid media service response: %w", err)
+	}
+	return variants, nil
+}
+
+// WriteUploadError passes image rejections from the media service on to the
+// client. Other failures are logged and reported as a bad gateway.
+func WriteUploadError(w http.ResponseWriter, err error) {
+	var mediaErr *Error
+	if errors.As(err, &mediaErr) && mediaErr.Status >= 400 && mediaErr.Status < 500 {
+		http.Error(w, mediaErr.Message, mediaErr.Status)
+		return
+	}
+	log.Printf("Failed to upload image: %v", err)
+	http.Error(w, "Failed to upload image", http.StatusBadGateway)
+}
@@ -0,0 +1,43 @@
+package media
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ImageVariant is one rendered size of an uploaded image. WebPURL is empty
+// when the service could not encode WebP.
+type ImageVariant struct {
+	URL     string `json:"url"`
+	WebPURL string `json:"webp_url,omitempty"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// ImageVariants maps variant names ("thumbnail", "medium", "large") to the
+// rendered files. It is stored as a JSON column.
+type ImageVariants map[string]ImageVariant
+
+func (v ImageVariants) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func (v *ImageVariants) Scan(value interface{}) error {
+	var data []byte
+	switch raw := value.(type) {
+	case []byte:
+		data = raw
+	case string:
+		data = []byte(raw)
+	case nil:
+		*v = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into ImageVariants", value)
+	}
+	return json.Unmarshal(data, v)
+}
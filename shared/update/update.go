@@ -0,0 +1,103 @@
+// Package update holds the parts of admin update requests that do not
+// depend on what is being updated: reading the sent fields, merging a
+// partial update over the current record and If-Match checks.
+package update
+
+import (
+	"diplomaPorject/backend/shared/bulk"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrPreconditionFailed = errors.New("the resource was changed since it was read; fetch it again and retry")
+
+// InvalidError marks a validation failure found while applying an update
+// inside a transaction.
+type InvalidError struct {
+	Err error
+}
+
+func (e *InvalidError) Error() string { return e.Err.Error() }
+func (e *InvalidError) Unwrap() error { return e.Err }
+
+// EntityTag is the ETag of a row, derived from its UpdatedAt. Postgres keeps
+// microseconds, so finer precision would not survive a round trip.
+func EntityTag(updatedAt time.Time) string {
+	return fmt.Sprintf(`"%x"`, updatedAt.UnixMicro())
+}
+
+// IfMatch reports whether the request's If-Match header, if any, matches the
+// current ETag.
+func IfMatch(r *http.Request, updatedAt time.Time) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	current := EntityTag(updatedAt)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadRequest reads the fields of an update, sent as a JSON object or as a
+// multipart form, and the replacement "image" file of a multipart request.
+// Only fields in columns other than "id" are accepted.
+func ReadRequest(r *http.Request, columns []string) (map[string]string, multipart.File, error) {
+	editable := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column != "id" {
+			editable = append(editable, column)
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		fields, err := bulk.ReadObject(r.Body, editable)
+		return fields, nil, err
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return nil, nil, errors.New("Failed to parse form")
+	}
+	allowed := make(map[string]bool, len(editable))
+	for _, column := range editable {
+		allowed[column] = true
+	}
+	fields := make(map[string]string, len(r.MultipartForm.Value))
+	for name, values := range r.MultipartForm.Value {
+		if !allowed[name] {
+			return nil, nil, fmt.Errorf("unknown field %q", name)
+		}
+		fields[name] = strings.TrimSpace(values[0])
+	}
+
+	file, _, err := r.FormFile("image")
+	if errors.Is(err, http.ErrMissingFile) {
+		return fields, nil, nil
+	}
+	if err != nil {
+		return nil, nil, errors.New("Failed to read image")
+	}
+	return fields, file, nil
+}
+
+// MergeFields lays the sent fields over the current record of a row.
+func MergeFields(columns []string, record []interface{}, sent map[string]string) (map[string]string, error) {
+	merged, err := bulk.RecordFields(columns, record)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range sent {
+		merged[name] = value
+	}
+	return merged, nil
+}
@@ -0,0 +1,157 @@
+package update
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIfMatch(t *testing.T) {
+	updatedAt := time.Date(2025, 3, 3, 10, 0, 0, 123456789, time.UTC)
+	current := EntityTag(updatedAt)
+	stale := EntityTag(updatedAt.Add(-time.Second))
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"no header", "", true},
+		{"current tag", current, true},
+		{"stale tag", stale, false},
+		{"wildcard", "*", true},
+		{"one of several", stale + ", " + current, true},
+		{"none of several", stale + `, "0"`, false},
+		{"unquoted", strings.Trim(current, `"`), false},
+		{"weak tag", "W/" + current, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PATCH", "/admin/events/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			if got := IfMatch(r, updatedAt); got != tt.want {
+				t.Errorf("IfMatch(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityTagIgnoresNanoseconds(t *testing.T) {
+	updatedAt := time.Date(2025, 3, 3, 10, 0, 0, 123456789, time.UTC)
+	stored := updatedAt.Truncate(time.Microsecond)
+	if EntityTag(updatedAt) != EntityTag(stored) {
+		t.Errorf("EntityTag changed when the time was stored with microsecond precision")
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	columns := []string{"id", "title", "price", "latitude"}
+	price := 2500.0
+	record := []interface{}{uint(4), "Concert", &price, (*float64)(nil)}
+
+	tests := []struct {
+		name string
+		sent map[string]string
+		want map[string]string
+	}{
+		{
+			name: "nothing sent",
+			sent: map[string]string{},
+			want: map[string]string{"id": "4", "title": "Concert", "price": "2500", "latitude": ""},
+		},
+		{
+			name: "one field",
+			sent: map[string]string{"title": "Opera"},
+			want: map[string]string{"id": "4", "title": "Opera", "price": "2500", "latitude": ""},
+		},
+		{
+			name: "clearing a field",
+			sent: map[string]string{"price": ""},
+			want: map[string]string{"id": "4", "title": "Concert", "price": "", "latitude": ""},
+		},
+		{
+			name: "setting an empty field",
+			sent: map[string]string{"latitude": "43.2"},
+			want: map[string]string{"id": "4", "title": "Concert", "price": "2500", "latitude": "43.2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeFields(columns, record, tt.sent)
+			if err != nil {
+				t.Fatalf("MergeFields: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeFields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRequest(t *testing.T) {
+	columns := []string{"id", "title", "price"}
+
+	t.Run("json", func(t *testing.T) {
+		r := httptest.NewRequest("PATCH", "/", strings.NewReader(`{"title": " Opera "}`))
+		r.Header.Set("Content-Type", "application/json")
+		fields, file, err := ReadRequest(r, columns)
+		if err != nil {
+			t.Fatalf("ReadRequest: %v", err)
+		}
+		if file != nil {
+			t.Errorf("got an image from a JSON request")
+		}
+		if want := map[string]string{"title": "Opera"}; !reflect.DeepEqual(fields, want) {
+			t.Errorf("fields = %v, want %v", fields, want)
+		}
+	})
+
+	t.Run("json id", func(t *testing.T) {
+		r := httptest.NewRequest("PATCH", "/", strings.NewReader(`{"id": 9}`))
+		if _, _, err := ReadRequest(r, columns); err == nil {
+			t.Errorf("ReadRequest accepted a change to the id")
+		}
+	})
+
+	t.Run("multipart with image", func(t *testing.T) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField("price", " 1500 ")
+		part, _ := writer.CreateFormFile("image", "cover.jpg")
+		part.Write([]byte("jpeg"))
+		writer.Close()
+
+		r := httptest.NewRequest("PATCH", "/", &body)
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		fields, file, err := ReadRequest(r, columns)
+		if err != nil {
+			t.Fatalf("ReadRequest: %v", err)
+		}
+		if want := map[string]string{"price": "1500"}; !reflect.DeepEqual(fields, want) {
+			t.Errorf("fields = %v, want %v", fields, want)
+		}
+		if file == nil {
+			t.Fatalf("image missing")
+		}
+		file.Close()
+	})
+
+	t.Run("multipart unknown field", func(t *testing.T) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField("admin_id", "1")
+		writer.Close()
+
+		r := httptest.NewRequest("PATCH", "/", &body)
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		if _, _, err := ReadRequest(r, columns); err == nil {
+			t.Errorf("ReadRequest accepted an unknown field")
+		}
+	})
+}